@@ -0,0 +1,231 @@
+// Package ast declares the types used to represent the syntax tree of a
+// HAML template, as returned by gohaml.Parse.
+package ast
+
+// Pos is the position of a node in the template. Line and Column are
+// 1-based; Column counts the characters of indentation before the node.
+type Pos struct {
+	Line   int
+	Column int
+}
+
+// Node is implemented by every node of the tree.
+type Node interface {
+	Position() Pos
+	node()
+}
+
+// File is a parsed template.
+type File struct {
+	Nodes []Node
+}
+
+// Value is the content of a tag or one side of an attribute: either literal
+// text, or a path such as post.Author.Name looked up in the scope.
+type Value struct {
+	Text   string
+	IsPath bool
+}
+
+// Expr is a value written in code: a literal string, int or float64, or,
+// when Path is not empty, a path looked up in the scope.
+type Expr struct {
+	Literal interface{}
+	Path    string
+}
+
+// Attr is an attribute of a tag, written with the #id and .class shorthands
+// or in a {:key => value} hash. An Attr with an empty Key is a splat, such
+// as {extraAttrs}: its Value is the path of a map or struct whose entries
+// are added as attributes.
+type Attr struct {
+	Key   Value
+	Value Value
+}
+
+type (
+	// Doctype is a !!! line; Value holds the rest of the line, such as 5
+	// or Strict.
+	Doctype struct {
+		Pos   Pos
+		Value string
+	}
+
+	// Tag is an element. Content holds the text following the tag on its
+	// line; NoNewline is set by < and SelfClose by /.
+	Tag struct {
+		Pos       Pos
+		Name      string
+		Attrs     []*Attr
+		Content   Value
+		NoNewline bool
+		SelfClose bool
+		Children  []Node
+	}
+
+	// Text is a line of plain text, or a path written with =.
+	Text struct {
+		Pos       Pos
+		Content   Value
+		NoNewline bool
+	}
+
+	// Comment is a -# line; the lines nested below it are not rendered.
+	Comment struct {
+		Pos      Pos
+		Text     string
+		Children []Node
+	}
+
+	// Assign is - name := value.
+	Assign struct {
+		Pos   Pos
+		Name  string
+		Value Expr
+	}
+
+	// Range is - for key, value := range target, where target is a path or
+	// a literal such as 10.
+	Range struct {
+		Pos      Pos
+		Key      string
+		Value    string
+		Target   Expr
+		Children []Node
+	}
+
+	// Def is - def name(params), the definition of a mixin.
+	Def struct {
+		Pos      Pos
+		Name     string
+		Params   []string
+		Children []Node
+	}
+
+	// Call is +name(args), the call of a mixin. Its children are rendered
+	// where the mixin yields.
+	Call struct {
+		Pos      Pos
+		Name     string
+		Args     []Expr
+		Children []Node
+	}
+
+	// Yield is - yield inside the definition of a mixin.
+	Yield struct {
+		Pos Pos
+	}
+)
+
+func (self *Doctype) Position() Pos { return self.Pos }
+func (self *Tag) Position() Pos     { return self.Pos }
+func (self *Text) Position() Pos    { return self.Pos }
+func (self *Comment) Position() Pos { return self.Pos }
+func (self *Assign) Position() Pos  { return self.Pos }
+func (self *Range) Position() Pos   { return self.Pos }
+func (self *Def) Position() Pos     { return self.Pos }
+func (self *Call) Position() Pos    { return self.Pos }
+func (self *Yield) Position() Pos   { return self.Pos }
+
+func (self *Doctype) node() {}
+func (self *Tag) node()     {}
+func (self *Text) node()    {}
+func (self *Comment) node() {}
+func (self *Assign) node()  {}
+func (self *Range) node()   {}
+func (self *Def) node()     {}
+func (self *Call) node()    {}
+func (self *Yield) node()   {}
+
+// Attr returns the value of the attribute with the given literal key, and
+// whether the tag has one.
+func (self *Tag) Attr(key string) (value Value, ok bool) {
+	for _, attr := range self.Attrs {
+		if !attr.Key.IsPath && attr.Key.Text == key {
+			return attr.Value, true
+		}
+	}
+	return
+}
+
+// SetAttr replaces the value of the attributes with the given literal key,
+// or adds one.
+func (self *Tag) SetAttr(key string, value Value) {
+	attrs := self.Attrs[:0]
+	set := false
+	for _, attr := range self.Attrs {
+		if !attr.Key.IsPath && attr.Key.Text == key {
+			if set {
+				continue
+			}
+			attr.Value, set = value, true
+		}
+		attrs = append(attrs, attr)
+	}
+	self.Attrs = attrs
+	if !set {
+		self.Attrs = append(self.Attrs, &Attr{Value{Text: key}, value})
+	}
+}
+
+// Copy returns a copy of the file whose nodes can be changed without
+// changing the nodes of f.
+func (self *File) Copy() *File {
+	return &File{Nodes: copyNodes(self.Nodes)}
+}
+
+func copyNodes(nodes []Node) []Node {
+	if nodes == nil {
+		return nil
+	}
+	copied := make([]Node, len(nodes))
+	for i, n := range nodes {
+		copied[i] = copyNode(n)
+	}
+	return copied
+}
+
+func copyNode(n Node) Node {
+	switch n := n.(type) {
+	case *Doctype:
+		c := *n
+		return &c
+	case *Tag:
+		c := *n
+		c.Attrs = nil
+		for _, attr := range n.Attrs {
+			a := *attr
+			c.Attrs = append(c.Attrs, &a)
+		}
+		c.Children = copyNodes(n.Children)
+		return &c
+	case *Text:
+		c := *n
+		return &c
+	case *Comment:
+		c := *n
+		c.Children = copyNodes(n.Children)
+		return &c
+	case *Assign:
+		c := *n
+		return &c
+	case *Range:
+		c := *n
+		c.Children = copyNodes(n.Children)
+		return &c
+	case *Def:
+		c := *n
+		c.Params = append([]string(nil), n.Params...)
+		c.Children = copyNodes(n.Children)
+		return &c
+	case *Call:
+		c := *n
+		c.Args = append([]Expr(nil), n.Args...)
+		c.Children = copyNodes(n.Children)
+		return &c
+	case *Yield:
+		c := *n
+		return &c
+	}
+	return n
+}
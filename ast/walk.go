@@ -0,0 +1,68 @@
+package ast
+
+import "fmt"
+
+// A Visitor's Visit method is invoked for each node encountered by Walk.
+// If the result visitor w is not nil, Walk visits each of the children of
+// node with the visitor w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Children returns the nodes nested below n.
+func Children(n Node) []Node {
+	switch n := n.(type) {
+	case *Tag:
+		return n.Children
+	case *Comment:
+		return n.Children
+	case *Range:
+		return n.Children
+	case *Def:
+		return n.Children
+	case *Call:
+		return n.Children
+	case *Doctype, *Text, *Assign, *Yield:
+		return nil
+	}
+	panic(fmt.Sprintf("ast.Children: unexpected node type %T", n))
+}
+
+// Walk traverses the nodes of a file, or a node and its children, in depth
+// first order: it starts by calling v.Visit(node); node must not be nil.
+func Walk(v Visitor, node interface{}) {
+	switch n := node.(type) {
+	case *File:
+		for _, child := range n.Nodes {
+			Walk(v, child)
+		}
+		return
+	case Node:
+		if v = v.Visit(n); v == nil {
+			return
+		}
+		for _, child := range Children(n) {
+			Walk(v, child)
+		}
+		v.Visit(nil)
+		return
+	}
+	panic(fmt.Sprintf("ast.Walk: unexpected node type %T", node))
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses the nodes of a file, or a node and its children, in
+// depth first order: it starts by calling f(node); node must not be nil. If
+// f returns true, Inspect invokes f for each of the children of node,
+// followed by a call of f(nil).
+func Inspect(node interface{}, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
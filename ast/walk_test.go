@@ -0,0 +1,74 @@
+package ast
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func testFile() *File {
+	return &File{Nodes: []Node{
+		&Doctype{Pos{1, 1}, "5"},
+		&Tag{Pos: Pos{2, 1}, Name: "ul", Children: []Node{
+			&Range{Pos: Pos{3, 3}, Key: "i", Value: "v", Target: Expr{Path: "items"}, Children: []Node{
+				&Tag{Pos: Pos{4, 5}, Name: "li", Content: Value{"v", true}},
+			}},
+			&Comment{Pos: Pos{5, 3}, Text: "done"},
+		}},
+		&Text{Pos: Pos{6, 1}, Content: Value{Text: "bye"}},
+	}}
+}
+
+func TestInspect(t *testing.T) {
+	var visited []string
+	Inspect(testFile(), func(n Node) bool {
+		if n == nil {
+			visited = append(visited, "end")
+			return false
+		}
+		visited = append(visited, fmt.Sprintf("%T@%d:%d", n, n.Position().Line, n.Position().Column))
+		_, isRange := n.(*Range)
+		return !isRange
+	})
+	expected := "*ast.Doctype@1:1 end *ast.Tag@2:1 *ast.Range@3:3 *ast.Comment@5:3 end end *ast.Text@6:1 end"
+	if output := strings.Join(visited, " "); output != expected {
+		t.Errorf("expected %q\ngot      %q", expected, output)
+	}
+}
+
+type countVisitor map[string]int
+
+func (self countVisitor) Visit(n Node) Visitor {
+	if tag, ok := n.(*Tag); ok {
+		self[tag.Name]++
+	}
+	return self
+}
+
+func TestWalk(t *testing.T) {
+	counts := countVisitor{}
+	Walk(counts, testFile())
+	if counts["ul"] != 1 || counts["li"] != 1 || len(counts) != 2 {
+		t.Errorf("unexpected counts %v", counts)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	f := testFile()
+	c := f.Copy()
+	tag := c.Nodes[1].(*Tag)
+	tag.SetAttr("class", Value{Text: "list"})
+	tag.Children[0].(*Range).Children[0].(*Tag).Name = "span"
+	c.Nodes = append(c.Nodes, &Yield{})
+
+	counts := countVisitor{}
+	Walk(counts, f)
+	if _, ok := f.Nodes[1].(*Tag).Attr("class"); ok || counts["li"] != 1 || len(f.Nodes) != 3 {
+		t.Errorf("expected the original to be unchanged, got %v", counts)
+	}
+	counts = countVisitor{}
+	Walk(counts, c)
+	if counts["span"] != 1 || counts["li"] != 0 {
+		t.Errorf("expected the copy to be changed, got %v", counts)
+	}
+}
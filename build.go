@@ -0,0 +1,201 @@
+package gohaml
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+/*
+Build renders the templates below srcDir to HTML files below outDir, with the same
+mapping of paths as NewHamlHandler: about.haml becomes about.html, docs/index.haml
+docs/index.html. Every page is rendered with a copy of scope that also holds its URL
+path as path. The files that are not templates are copied as they are.
+
+Templates whose names start with an underscore are partials: the other templates can
+call their mixins, as with NewFileSystemLoader, but they are not written. Templates and
+directories named in brackets, such as users/[id].haml, only make sense to a server and
+are skipped.
+
+The transformers are run on every template, as with Engine.Transform.
+
+Build is incremental: a page is only rendered again when its template, or any partial,
+is newer than the file written for it, and a file is only copied when it is newer than
+its copy. Build returns the paths of the files it wrote, relative to outDir. Since it
+cannot tell when scope changed, BuildWithOptions has to be used when that matters.
+*/
+func Build(srcDir string, outDir string, scope map[string]interface{}, transformers ...Transformer) (written []string, err error) {
+	return BuildWithOptions(srcDir, outDir, BuildOptions{Scope: scope, Transformers: transformers})
+}
+
+/*
+BuildOptions holds the options of BuildWithOptions.
+
+The Scope field contains the values every page is rendered with, and the ScopeTime
+field the time they last changed, such as the modification time of the file they were
+read from: pages written before it are rendered again, as when a partial changes.
+
+The Force field renders every page and copies every file, whether or not they are up
+to date, for instance after a change to what Setup does.
+
+The Transformers field contains the transformers run on every template, as with
+Engine.Transform, and the Setup function, if any, is called with the engine of every
+page before it is rendered to set its Indentation, Autoclose, formatters and the like.
+*/
+type BuildOptions struct {
+	Scope        map[string]interface{}
+	ScopeTime    time.Time
+	Force        bool
+	Transformers []Transformer
+	Setup        func(*Engine) error
+}
+
+// BuildWithOptions renders a site like Build, as opts describes.
+func BuildWithOptions(srcDir string, outDir string, opts BuildOptions) (written []string, err error) {
+	var loader Loader
+	if loader, err = NewFileSystemLoader(srcDir, opts.Transformers...); err != nil {
+		return
+	}
+
+	// outDir may be below srcDir, and is not part of the site.
+	var absOut string
+	if absOut, err = filepath.Abs(outDir); err != nil {
+		return
+	}
+
+	var pages, assets []string
+	var partialsTime time.Time
+	err = filepath.Walk(srcDir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if abs, err := filepath.Abs(path); err == nil && abs == absOut {
+			return filepath.SkipDir
+		}
+		name := fi.Name()
+		if _, dynamic := dynamicSegment(name, fi.IsDir()); dynamic {
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if fi.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		switch {
+		case filepath.Ext(name) != ".haml":
+			assets = append(assets, rel)
+		case isPartial(name):
+			if fi.ModTime().After(partialsTime) {
+				partialsTime = fi.ModTime()
+			}
+		default:
+			pages = append(pages, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	since := partialsTime
+	if opts.ScopeTime.After(since) {
+		since = opts.ScopeTime
+	}
+	for _, rel := range pages {
+		out := strings.TrimSuffix(rel, ".haml") + ".html"
+		var fresh bool
+		if fresh, err = upToDate(filepath.Join(srcDir, rel), filepath.Join(outDir, out), since); err != nil {
+			return
+		} else if fresh && !opts.Force {
+			continue
+		}
+		var engine *Engine
+		if engine, err = loader.Load(filepath.ToSlash(rel)); err != nil {
+			return written, templateError("build", rel, err)
+		}
+		if opts.Setup != nil {
+			if err = opts.Setup(engine); err != nil {
+				return written, templateError("build", rel, err)
+			}
+		}
+		pageScope := map[string]interface{}{"path": "/" + filepath.ToSlash(out)}
+		for key, value := range opts.Scope {
+			pageScope[key] = value
+		}
+		var output string
+		if output, err = render(engine, pageScope); err != nil {
+			return written, templateError("build", rel, err)
+		}
+		if err = writeFile(filepath.Join(outDir, out), strings.NewReader(output)); err != nil {
+			return
+		}
+		written = append(written, out)
+	}
+	for _, rel := range assets {
+		src, dst := filepath.Join(srcDir, rel), filepath.Join(outDir, rel)
+		var fresh bool
+		if fresh, err = upToDate(src, dst, time.Time{}); err != nil {
+			return
+		} else if fresh && !opts.Force {
+			continue
+		}
+		if err = copyFile(src, dst); err != nil {
+			return
+		}
+		written = append(written, rel)
+	}
+	return
+}
+
+// templateError names the template an error comes from.
+func templateError(op string, rel string, err error) error {
+	if serr, ok := err.(*SyntaxError); ok {
+		return &SyntaxError{serr.Line, filepath.ToSlash(rel) + ": " + serr.Msg}
+	}
+	return &os.PathError{Op: op, Path: filepath.ToSlash(rel), Err: err}
+}
+
+// upToDate tells whether dst was written after src and after the given
+// time was last changed.
+func upToDate(src string, dst string, since time.Time) (bool, error) {
+	sfi, err := os.Stat(src)
+	if err != nil {
+		return false, err
+	}
+	dfi, err := os.Stat(dst)
+	if err != nil {
+		return false, nil
+	}
+	return !sfi.ModTime().After(dfi.ModTime()) && !since.After(dfi.ModTime()), nil
+}
+
+func writeFile(path string, r io.Reader) (err error) {
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return
+	}
+	var f *os.File
+	if f, err = os.Create(path); err != nil {
+		return
+	}
+	if _, err = io.Copy(f, r); err != nil {
+		f.Close()
+		return
+	}
+	return f.Close()
+}
+
+func copyFile(src string, dst string) (err error) {
+	var f *os.File
+	if f, err = os.Open(src); err != nil {
+		return
+	}
+	defer f.Close()
+	return writeFile(dst, f)
+}
@@ -0,0 +1,142 @@
+package gohaml
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readOutput(t *testing.T, dir string, name string) string {
+	b, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestBuild(t *testing.T) {
+	src := writeTemplates(t, map[string]string{
+		"index.haml":       "%h1= title",
+		"about.haml":       "+card(path)",
+		"docs/index.haml":  "%p= path",
+		"_mixins.haml":     "- def card(body)\n  .card= body",
+		"users/[id].haml":  "= id",
+		"[slug]/page.haml": "= slug",
+		"css/site.css":     "body {}",
+	})
+	out := filepath.Join(t.TempDir(), "public")
+
+	written, err := Build(src, out, map[string]interface{}{"title": "Home"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(written)
+	if got := strings.Join(written, " "); got != "about.html css/site.css docs/index.html index.html" {
+		t.Errorf("unexpected files written: %s", got)
+	}
+	for i, io := range []testcase{
+		testcase{"index.html", "<h1>Home</h1>"},
+		testcase{"about.html", `<div class="card">/about.html</div>`},
+		testcase{"docs/index.html", "<p>/docs/index.html</p>"},
+		testcase{"css/site.css", "body {}"},
+	} {
+		if output := readOutput(t, out, io.input); output != io.expected {
+			t.Errorf("(%d) Input    %q\nexpected %q\ngot      %q", i, io.input, io.expected, output)
+		}
+	}
+	for _, name := range []string{"_mixins.html", "users", "[slug]"} {
+		if _, err := os.Stat(filepath.Join(out, name)); err == nil {
+			t.Errorf("expected %s not to be written", name)
+		}
+	}
+}
+
+func TestBuildIncremental(t *testing.T) {
+	src := writeTemplates(t, map[string]string{
+		"a.haml":       "+card(\"a\")",
+		"b.haml":       "%p b",
+		"_mixins.haml": "- def card(body)\n  .card= body",
+		"logo.png":     "png",
+	})
+	out := t.TempDir()
+	if _, err := Build(src, out, nil); err != nil {
+		t.Fatal(err)
+	}
+	if written, err := Build(src, out, nil); err != nil || len(written) != 0 {
+		t.Errorf("expected nothing to be written again, got %v %v", written, err)
+	}
+
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(filepath.Join(src, "b.haml"), later, later)
+	if written, err := Build(src, out, nil); err != nil || strings.Join(written, " ") != "b.html" {
+		t.Errorf("expected b.html to be written again, got %v %v", written, err)
+	}
+
+	// every page may call the mixins of a partial.
+	later = later.Add(time.Hour)
+	os.Chtimes(filepath.Join(src, "_mixins.haml"), later, later)
+	written, err := Build(src, out, nil)
+	sort.Strings(written)
+	if err != nil || strings.Join(written, " ") != "a.html b.html" {
+		t.Errorf("expected every page to be written again, got %v %v", written, err)
+	}
+}
+
+func TestBuildWithOptions(t *testing.T) {
+	src := writeTemplates(t, map[string]string{"a.haml": "%p= title\n%br", "logo.png": "png"})
+	out := t.TempDir()
+	opts := BuildOptions{Scope: map[string]interface{}{"title": "one"}}
+	if _, err := BuildWithOptions(src, out, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	// a newer scope renders the pages again, but does not copy the files.
+	opts.Scope["title"] = "two"
+	opts.ScopeTime = time.Now().Add(time.Hour)
+	if written, err := BuildWithOptions(src, out, opts); err != nil || strings.Join(written, " ") != "a.html" {
+		t.Errorf("expected a.html to be written again, got %v %v", written, err)
+	}
+
+	opts.Force = true
+	opts.Setup = func(engine *Engine) error {
+		engine.Autoclose = false
+		return nil
+	}
+	opts.Scope["title"] = "three"
+	written, err := BuildWithOptions(src, out, opts)
+	sort.Strings(written)
+	if err != nil || strings.Join(written, " ") != "a.html logo.png" {
+		t.Errorf("expected every file to be written again, got %v %v", written, err)
+	}
+	if output := readOutput(t, out, "a.html"); output != "<p>three</p>\n<br>" {
+		t.Errorf("expected the engine to be set up, got %q", output)
+	}
+
+	opts.Setup = func(engine *Engine) error { return errors.New("bad setup") }
+	if _, err := BuildWithOptions(src, out, opts); err == nil || err.Error() != "build a.haml: bad setup" {
+		t.Errorf("expected the setup error, got %v", err)
+	}
+}
+
+func TestBuildOutputInSource(t *testing.T) {
+	src := writeTemplates(t, map[string]string{"index.haml": "%p", "public/old.haml": "%p old"})
+	written, err := Build(src, filepath.Join(src, "public"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(written, " ") != "index.html" {
+		t.Errorf("expected the output directory to be skipped, got %v", written)
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	src := writeTemplates(t, map[string]string{"bad.haml": "%p{:a => }"})
+	_, err := Build(src, t.TempDir(), nil)
+	if err == nil || !strings.Contains(err.Error(), "bad.haml") {
+		t.Errorf("expected an error naming bad.haml, got %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+package gohaml
+
+import (
+	"container/list"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+CacheOptions turns on the output cache of the handler returned by
+NewHamlHandlerWithOptions.
+
+The Key field contains the function that names the page a request is answered with,
+and is required. Requests with the same key are answered with the same markup, without
+rendering the template again, and requests for which it returns "" are not cached.
+The Scope function is still called for every request, so that it can reject one, but
+the values it returns are only used when the page is rendered: the key has to tell
+apart every request whose scope, including the headers, cookies and form values the
+template uses, leads to different markup. CacheKey builds one from the path, selected
+query parameters and cookies.
+
+The TTL field contains how long a page is kept; zero keeps it until it is evicted.
+
+The MaxEntries field contains the number of pages kept, evicting the least recently
+used one to make room for another; zero means 1000.
+
+A page is also dropped when the modification time of its template changes, and when
+the partials it may call mixins from change, are added or are removed.
+*/
+type CacheOptions struct {
+	Key        func(*http.Request) string
+	TTL        time.Duration
+	MaxEntries int
+}
+
+// CacheKey returns a key function for CacheOptions made of the path of the
+// URL and the values of the given query parameters and cookies.
+func CacheKey(params []string, cookies []string) func(*http.Request) string {
+	params = append([]string(nil), params...)
+	sort.Strings(params)
+	return func(r *http.Request) string {
+		var key strings.Builder
+		key.WriteString(r.URL.Path)
+		query := r.URL.Query()
+		for _, name := range params {
+			key.WriteString("&" + url.QueryEscape(name) + "=" + url.QueryEscape(query.Get(name)))
+		}
+		for _, name := range cookies {
+			value := ""
+			if c, err := r.Cookie(name); err == nil {
+				value = c.Value
+			}
+			key.WriteString(";" + url.QueryEscape(name) + "=" + url.QueryEscape(value))
+		}
+		return key.String()
+	}
+}
+
+// page is a rendered page and the values its headers are built from. A
+// page has a modification time when it does not depend on the scope.
+type page struct {
+	sync.Mutex
+	body     string
+	etag     string
+	modtime  time.Time
+	variants map[string]string
+}
+
+// pageCache keeps rendered pages by key, in the order they were last used.
+type pageCache struct {
+	sync.Mutex
+	opts    CacheOptions
+	order   *list.List
+	entries map[string]*list.Element
+	now     func() time.Time
+}
+
+type cacheEntry struct {
+	key     string
+	page    *page
+	version time.Time
+	expires time.Time
+}
+
+func newPageCache(opts CacheOptions) *pageCache {
+	if opts.MaxEntries <= 0 {
+		opts.MaxEntries = 1000
+	}
+	return &pageCache{opts: opts, order: list.New(), entries: make(map[string]*list.Element), now: time.Now}
+}
+
+// get returns the page kept for key, if it was rendered from the given
+// version of its templates and has not expired.
+func (c *pageCache) get(key string, version time.Time) *page {
+	c.Lock()
+	defer c.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	entry := e.Value.(*cacheEntry)
+	if !entry.version.Equal(version) || !entry.expires.IsZero() && !c.now().Before(entry.expires) {
+		c.order.Remove(e)
+		delete(c.entries, key)
+		return nil
+	}
+	c.order.MoveToFront(e)
+	return entry.page
+}
+
+func (c *pageCache) put(key string, version time.Time, p *page) {
+	c.Lock()
+	defer c.Unlock()
+	entry := &cacheEntry{key: key, page: p, version: version}
+	if c.opts.TTL > 0 {
+		entry.expires = c.now().Add(c.opts.TTL)
+	}
+	if e, ok := c.entries[key]; ok {
+		e.Value = entry
+		c.order.MoveToFront(e)
+		return
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.opts.MaxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
@@ -0,0 +1,185 @@
+package gohaml
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// renderCounter counts the times it is written, which is once for every
+// page rendered with = n.
+type renderCounter struct{ n *int }
+
+func (self renderCounter) String() string {
+	*self.n++
+	return strconv.Itoa(*self.n)
+}
+
+// countingHandler returns a handler with the given cache options that
+// counts the pages it renders.
+func countingHandler(t *testing.T, dir string, opts *CacheOptions) (*httpHamlHandler, *int) {
+	renders := new(int)
+	h, err := NewHamlHandlerWithOptions(dir, HandlerOptions{Cache: opts, Scope: func(r *http.Request) (map[string]interface{}, error) {
+		return map[string]interface{}{"n": renderCounter{renders}}, nil
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h.(*httpHamlHandler), renders
+}
+
+func requestURI(r *http.Request) string {
+	return r.URL.RequestURI()
+}
+
+func TestHandlerCache(t *testing.T) {
+	dir := writeTemplates(t, map[string]string{"page.haml": "= n"})
+	h, renders := countingHandler(t, dir, &CacheOptions{Key: requestURI})
+	for i, io := range []testcase{
+		testcase{"/page.html", "1"},
+		testcase{"/page.html", "1"},
+		testcase{"/page.html?a=1", "2"},
+		testcase{"/page.html?a=1", "2"},
+		testcase{"/page.html", "1"},
+	} {
+		w := serve(t, h, httptest.NewRequest("GET", io.input, nil))
+		if w.Body.String() != io.expected {
+			t.Errorf("(%d) Path     %q\nexpected %q\ngot      %q", i, io.input, io.expected, w.Body.String())
+		}
+	}
+	if *renders != 2 {
+		t.Errorf("expected 2 renders, got %d", *renders)
+	}
+
+	req := httptest.NewRequest("GET", "/page.html", nil)
+	req.Header.Set("If-None-Match", serve(t, h, req).Header().Get("ETag"))
+	if w := serve(t, h, req); w.Code != http.StatusNotModified {
+		t.Errorf("expected a cached page to answer conditional requests, got %d", w.Code)
+	}
+
+	// a new version of the template drops the page.
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(filepath.Join(dir, "page.haml"), later, later)
+	if w := serve(t, h, httptest.NewRequest("GET", "/page.html", nil)); w.Body.String() != "3" {
+		t.Errorf("expected the page to be rendered again, got %q", w.Body.String())
+	}
+}
+
+func TestHandlerCachePartials(t *testing.T) {
+	dir := writeTemplates(t, map[string]string{"page.haml": "+item(n)", "_mixins.haml": "- def item(v)\n  %p= v"})
+	h, _ := countingHandler(t, dir, &CacheOptions{Key: requestURI})
+	get := func() string {
+		return serve(t, h, httptest.NewRequest("GET", "/page.html", nil)).Body.String()
+	}
+	if output := get(); output != "<p>1</p>" {
+		t.Fatalf("unexpected page %q", output)
+	}
+	if output := get(); output != "<p>1</p>" {
+		t.Errorf("expected the kept page, got %q", output)
+	}
+
+	// the page is rendered again when a partial changes ...
+	later := time.Now().Add(time.Hour)
+	path := filepath.Join(dir, "_mixins.haml")
+	os.WriteFile(path, []byte("- def item(v)\n  %b= v"), 0644)
+	os.Chtimes(path, later, later)
+	if output := get(); output != "<b>2</b>" {
+		t.Errorf("expected the page with the changed mixin, got %q", output)
+	}
+
+	// ... and when one is added.
+	os.WriteFile(filepath.Join(dir, "_more.haml"), []byte("- def item(v)\n  %i= v"), 0644)
+	os.Chtimes(filepath.Join(dir, "_more.haml"), later.Add(time.Hour), later.Add(time.Hour))
+	if output := get(); output != "<i>3</i>" {
+		t.Errorf("expected the page with the added mixin, got %q", output)
+	}
+}
+
+func TestHandlerCacheKey(t *testing.T) {
+	dir := writeTemplates(t, map[string]string{"page.haml": "= n"})
+	key := CacheKey([]string{"lang"}, []string{"theme"})
+	h, renders := countingHandler(t, dir, &CacheOptions{Key: func(r *http.Request) string {
+		if r.URL.Query().Get("nocache") != "" {
+			return ""
+		}
+		return key(r)
+	}})
+	get := func(path string, theme string) string {
+		req := httptest.NewRequest("GET", path, nil)
+		if theme != "" {
+			req.AddCookie(&http.Cookie{Name: "theme", Value: theme})
+		}
+		return serve(t, h, req).Body.String()
+	}
+	outputs := []string{
+		get("/page.html?lang=en&utm=x", ""),
+		get("/page.html?utm=y&lang=en", ""),
+		get("/page.html?lang=fr", ""),
+		get("/page.html?lang=en", "dark"),
+		get("/page.html?lang=en", "dark"),
+		get("/page.html?lang=en&nocache=1", ""),
+		get("/page.html?lang=en&nocache=1", ""),
+	}
+	expected := []string{"1", "1", "2", "3", "3", "4", "5"}
+	for i := range expected {
+		if outputs[i] != expected[i] {
+			t.Errorf("(%d) expected %q, got %q", i, expected[i], outputs[i])
+		}
+	}
+	if *renders != 5 {
+		t.Errorf("expected 5 renders, got %d", *renders)
+	}
+}
+
+func TestHandlerCacheEviction(t *testing.T) {
+	dir := writeTemplates(t, map[string]string{"page.haml": "= n"})
+	h, renders := countingHandler(t, dir, &CacheOptions{Key: requestURI, TTL: time.Minute, MaxEntries: 2})
+	now := time.Now()
+	h.cache.now = func() time.Time { return now }
+	for _, path := range []string{"/page.html?a", "/page.html?b", "/page.html?a", "/page.html?c", "/page.html?a", "/page.html?b"} {
+		serve(t, h, httptest.NewRequest("GET", path, nil))
+	}
+	// b was the least recently used page when c was added.
+	if *renders != 4 {
+		t.Errorf("expected 4 renders, got %d", *renders)
+	}
+
+	now = now.Add(time.Minute)
+	if w := serve(t, h, httptest.NewRequest("GET", "/page.html?a", nil)); w.Body.String() != "5" {
+		t.Errorf("expected an expired page to be rendered again, got %q", w.Body.String())
+	}
+}
+
+func TestHandlerCacheScope(t *testing.T) {
+	dir := writeTemplates(t, map[string]string{"page.haml": "= cookies.user"})
+	calls := 0
+	h, err := NewHamlHandlerWithOptions(dir, HandlerOptions{Cache: &CacheOptions{Key: requestURI}, Scope: func(r *http.Request) (map[string]interface{}, error) {
+		calls++
+		if _, err := r.Cookie("user"); err != nil {
+			return nil, &statusError{http.StatusForbidden, err}
+		}
+		return nil, nil
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("GET", "/page.html", nil)
+	req.AddCookie(&http.Cookie{Name: "user", Value: "jane"})
+	if w := serve(t, h, req); w.Body.String() != "jane" {
+		t.Errorf("expected the page of the user, got %q", w.Body.String())
+	}
+	if w := serve(t, h, httptest.NewRequest("GET", "/page.html", nil)); w.Code != http.StatusForbidden {
+		t.Errorf("expected the scope function to reject a request for a cached page, got %d %q", w.Code, w.Body.String())
+	}
+	if calls != 2 {
+		t.Errorf("expected the scope function to be called for every request, got %d calls", calls)
+	}
+
+	if _, err := NewHamlHandlerWithOptions(dir, HandlerOptions{Cache: &CacheOptions{}}); err == nil {
+		t.Errorf("expected an error for CacheOptions without a Key")
+	}
+}
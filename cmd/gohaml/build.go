@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/realistschuckle/gohaml"
+)
+
+// runBuild renders a directory of templates to a static site:
+//
+//	gohaml build [-data data.json] [-force] [-v] site/ public/
+//
+// Pages are rendered again when the data file is newer than them, and
+// -force rebuilds everything, for instance after changing the engine flags.
+func runBuild(args []string) (err error) {
+	fs := newFlagSet("build")
+	data := fs.String("data", "", "JSON or YAML file holding the scope of every page (- reads JSON from the standard input)")
+	force := fs.Bool("force", false, "render every page and copy every file, even when up to date")
+	verbose := fs.Bool("v", false, "print the files written")
+	ef := addEngineFlags(fs)
+	dirs, err := parseArgs(fs, args)
+	if err != nil {
+		return
+	}
+
+	if len(dirs) != 2 {
+		fs.Usage()
+		return errUsage
+	}
+	// report bad engine flags even when every page is up to date.
+	if err = ef.apply(new(gohaml.Engine)); err != nil {
+		return
+	}
+	opts := gohaml.BuildOptions{Scope: map[string]interface{}{}, Force: *force, Setup: ef.apply}
+	if *data != "" {
+		if opts.Scope, err = loadScope(*data); err != nil {
+			return
+		}
+		// the standard input may have changed since any build.
+		opts.ScopeTime = time.Now()
+		if fi, err := os.Stat(*data); err == nil && *data != "-" {
+			opts.ScopeTime = fi.ModTime()
+		}
+	}
+
+	written, err := gohaml.BuildWithOptions(dirs[0], dirs[1], opts)
+	if *verbose {
+		for _, path := range written {
+			fmt.Println(filepath.Join(dirs[1], path))
+		}
+	}
+	return
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/realistschuckle/gohaml"
+)
+
+// runCheck parses every template below the given files and directories and
+// reports the syntax errors as file:line: message.
+func runCheck(args []string) (err error) {
+	fs := newFlagSet("check")
+	paths, err := parseArgs(fs, args)
+	if err != nil {
+		return
+	}
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	failed := 0
+	for _, root := range paths {
+		err = filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if fi.IsDir() || filepath.Ext(path) != ".haml" {
+				return nil
+			}
+			b, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			if _, err = gohaml.NewEngine(string(b)); err != nil {
+				failed++
+				if serr, ok := err.(*gohaml.SyntaxError); ok {
+					fmt.Printf("%s:%d: %s\n", path, serr.Line, serr.Msg)
+				} else {
+					fmt.Printf("%s: %s\n", path, err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d template(s) with errors", failed)
+	}
+	return
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/realistschuckle/gohaml"
+)
+
+// runFmt formats templates. Without arguments it formats the standard input
+// to the standard output; directories are searched for .haml files.
+func runFmt(args []string) (err error) {
+	fs := newFlagSet("fmt")
+	write := fs.Bool("w", false, "write the result to the file instead of the standard output")
+	list := fs.Bool("l", false, "list the files whose formatting differs")
+	paths, err := parseArgs(fs, args)
+	if err != nil {
+		return
+	}
+
+	if len(paths) == 0 {
+		var b []byte
+		if b, err = ioutil.ReadAll(os.Stdin); err != nil {
+			return
+		}
+		if b, err = gohaml.Format(b); err != nil {
+			return
+		}
+		_, err = os.Stdout.Write(b)
+		return
+	}
+
+	for _, root := range paths {
+		err = filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if fi.IsDir() || (path != root && filepath.Ext(path) != ".haml") {
+				return nil
+			}
+			return formatFile(path, fi.Mode(), *write, *list)
+		})
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func formatFile(path string, mode os.FileMode, write bool, list bool) (err error) {
+	var src, b []byte
+	if src, err = ioutil.ReadFile(path); err != nil {
+		return
+	}
+	if b, err = gohaml.Format(src); err != nil {
+		if serr, ok := err.(*gohaml.SyntaxError); ok {
+			err = fmt.Errorf("%s:%d: %s", path, serr.Line, serr.Msg)
+		}
+		return
+	}
+	changed := !bytes.Equal(src, b)
+	if list && changed {
+		fmt.Println(path)
+	}
+	if write {
+		if changed {
+			err = ioutil.WriteFile(path, b, mode.Perm())
+		}
+		return
+	}
+	if !list {
+		_, err = os.Stdout.Write(b)
+	}
+	return
+}
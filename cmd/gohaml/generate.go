@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/realistschuckle/gohaml"
+)
+
+// runGenerate writes the Go render function for a template. Within go
+// generate, the package name defaults to the one of the invoking file:
+//
+//	//go:generate gohaml generate -type *UserPage user_page.haml
+func runGenerate(args []string) (err error) {
+	fs := newFlagSet("generate")
+	pkg := fs.String("pkg", os.Getenv("GOPACKAGE"), "package name of the generated file")
+	fn := fs.String("func", "", "name of the render function (default Render followed by the file name)")
+	typ := fs.String("type", "", "Go type of the data parameter, e.g. *UserPage")
+	out := fs.String("o", "", "output file (default file_haml.go next to the template)")
+	ef := addEngineFlags(fs)
+	files, err := parseArgs(fs, args)
+	if err != nil {
+		return
+	}
+
+	if len(files) != 1 {
+		fs.Usage()
+		return errUsage
+	}
+	if *typ == "" {
+		return errors.New("the -type flag is required")
+	}
+	if *pkg == "" {
+		return errors.New("the -pkg flag is required outside of go generate")
+	}
+	file := files[0]
+	name := strings.TrimSuffix(filepath.Base(file), ".haml")
+	if *fn == "" {
+		*fn = "Render" + exportedName(name)
+	}
+	if *out == "" {
+		*out = filepath.Join(filepath.Dir(file), name+"_haml.go")
+	}
+
+	var engine *gohaml.Engine
+	if engine, err = loadEngine(file); err != nil {
+		return
+	}
+	if err = ef.apply(engine); err != nil {
+		return
+	}
+
+	var buf bytes.Buffer
+	if err = engine.Generate(&buf, gohaml.GenerateOptions{Package: *pkg, Func: *fn, Type: *typ}); err != nil {
+		return
+	}
+	return ioutil.WriteFile(*out, buf.Bytes(), 0644)
+}
+
+// exportedName turns a file name such as user_page into UserPage.
+func exportedName(name string) string {
+	var buf bytes.Buffer
+	upper := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		buf.WriteRune(r)
+	}
+	return buf.String()
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/realistschuckle/gohaml"
+)
+
+// runHTMLToHaml converts an HTML file, or the standard input, to HAML and
+// writes it to the standard output.
+func runHTMLToHaml(args []string) (err error) {
+	fs := newFlagSet("html2haml")
+	files, err := parseArgs(fs, args)
+	if err != nil {
+		return
+	}
+	if len(files) > 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	var b []byte
+	if len(files) == 0 || files[0] == "-" {
+		b, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		b, err = ioutil.ReadFile(files[0])
+	}
+	if err != nil {
+		return
+	}
+
+	var haml string
+	if haml, err = gohaml.HTMLToHaml(string(b)); err != nil {
+		return
+	}
+	_, err = fmt.Fprintln(os.Stdout, haml)
+	return
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/realistschuckle/gohaml"
+)
+
+// fileIssue is a gohaml.LintIssue together with the template it was found
+// in, as written by lint -json.
+type fileIssue struct {
+	File string `json:"file"`
+	gohaml.LintIssue
+}
+
+// runLint lints every template below the given files and directories. The
+// targets of %include are resolved relative to the including template.
+func runLint(args []string) (err error) {
+	fs := newFlagSet("lint")
+	asJSON := fs.Bool("json", false, "write the issues as a JSON array")
+	paths, err := parseArgs(fs, args)
+	if err != nil {
+		return
+	}
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	issues := []fileIssue{}
+	failed := 0
+	for _, root := range paths {
+		err = filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if fi.IsDir() || filepath.Ext(path) != ".haml" {
+				return nil
+			}
+			b, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			loader, err := gohaml.NewFileSystemLoader(filepath.Dir(path))
+			if err != nil {
+				return err
+			}
+			for _, issue := range gohaml.Lint(string(b), loader) {
+				if issue.Severity == gohaml.SeverityError {
+					failed++
+				}
+				issues = append(issues, fileIssue{path, issue})
+			}
+			return nil
+		})
+		if err != nil {
+			return
+		}
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err = enc.Encode(issues); err != nil {
+			return
+		}
+	} else {
+		for _, issue := range issues {
+			fmt.Printf("%s:%s\n", issue.File, issue.LintIssue)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d error(s)", failed)
+	}
+	return
+}
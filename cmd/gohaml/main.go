@@ -0,0 +1,150 @@
+// Command gohaml works with HAML templates from the command line.
+//
+// Usage:
+//
+//	gohaml <command> [flags] [arguments]
+//
+// The commands are:
+//
+//	build       render a directory of templates to a static site
+//	check       report the syntax errors of templates
+//	fmt         rewrite templates in canonical form
+//	generate    compile a template to a Go render function
+//	html2haml   convert HTML to HAML
+//	lint        report likely mistakes in templates
+//	render      render a template to the standard output
+//
+// Run "gohaml <command> -h" for the flags of a command.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/realistschuckle/gohaml"
+)
+
+type command struct {
+	run   func(args []string) error
+	usage string
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"build":     {runBuild, "build [-data data.json] [-force] [-v] [flags] srcdir outdir"},
+		"check":     {runCheck, "check [dir|file.haml ...]"},
+		"fmt":       {runFmt, "fmt [-w] [-l] [dir|file.haml ...]"},
+		"generate":  {runGenerate, "generate [flags] file.haml"},
+		"html2haml": {runHTMLToHaml, "html2haml [file.html]"},
+		"lint":      {runLint, "lint [-json] [dir|file.haml ...]"},
+		"render":    {runRender, "render file.haml [--data data.json] [flags]"},
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: gohaml <command> [flags] [arguments]\n\ncommands:\n")
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "\tgohaml %s\n", commands[name].usage)
+	}
+}
+
+// errUsage is returned by the commands for wrong arguments, after they
+// printed their usage.
+var errUsage = errors.New("wrong arguments")
+
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: gohaml %s\n", commands[name].usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseArgs parses the flags of a command, which may come before, after or
+// between its arguments, and returns the arguments. Wrong flags are
+// reported as errUsage, and -h as flag.ErrHelp.
+func parseArgs(fs *flag.FlagSet, args []string) (positional []string, err error) {
+	for {
+		if err = fs.Parse(args); err != nil {
+			if err != flag.ErrHelp {
+				err = errUsage
+			}
+			return
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// engineFlags mirror the fields of gohaml.Engine.
+type engineFlags struct {
+	indent      *string
+	noAutoclose *bool
+	format      *string
+}
+
+func addEngineFlags(fs *flag.FlagSet) *engineFlags {
+	return &engineFlags{
+		fs.String("indent", "tab", "indentation: tab, a number of spaces, or the literal string"),
+		fs.Bool("no-autoclose", false, "write <br> instead of <br /> for empty tags"),
+		fs.String("format", "xhtml", "output format: xhtml, or html which implies -no-autoclose"),
+	}
+}
+
+func (self *engineFlags) apply(engine *gohaml.Engine) error {
+	switch n, err := strconv.Atoi(*self.indent); {
+	case *self.indent == "tab":
+		engine.Indentation = "\t"
+	case err == nil && n >= 0:
+		engine.Indentation = strings.Repeat(" ", n)
+	default:
+		engine.Indentation = *self.indent
+	}
+	switch *self.format {
+	case "xhtml":
+		engine.Autoclose = !*self.noAutoclose
+	case "html":
+		engine.Autoclose = false
+	default:
+		return fmt.Errorf("unknown format %q", *self.format)
+	}
+	return nil
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "gohaml: unknown command %q\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+	switch err := cmd.run(os.Args[2:]); err {
+	case nil, flag.ErrHelp:
+	case errUsage:
+		os.Exit(2)
+	default:
+		fmt.Fprintf(os.Stderr, "gohaml %s: %s\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/realistschuckle/gohaml"
+)
+
+func TestParseArgs(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	data := fs.String("data", "", "")
+	ef := addEngineFlags(fs)
+	args, err := parseArgs(fs, []string{"--indent", "2", "page.haml", "--data", "data.json", "other.haml", "-no-autoclose"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(args, []string{"page.haml", "other.haml"}) {
+		t.Errorf("unexpected arguments %q", args)
+	}
+	if *data != "data.json" {
+		t.Errorf("unexpected data flag %q", *data)
+	}
+
+	engine, _ := gohaml.NewEngine("%br")
+	if err := ef.apply(engine); err != nil {
+		t.Fatal(err)
+	}
+	if engine.Indentation != "  " || engine.Autoclose {
+		t.Errorf("unexpected engine settings %q %v", engine.Indentation, engine.Autoclose)
+	}
+}
+
+func TestEngineFlagsFormat(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	ef := addEngineFlags(fs)
+	parseArgs(fs, []string{"--format", "html", "--indent", "tab"})
+
+	engine, _ := gohaml.NewEngine("%br")
+	if err := ef.apply(engine); err != nil {
+		t.Fatal(err)
+	}
+	if engine.Indentation != "\t" || engine.Autoclose {
+		t.Errorf("unexpected engine settings %q %v", engine.Indentation, engine.Autoclose)
+	}
+
+	parseArgs(fs, []string{"--format", "pdf"})
+	if err := ef.apply(engine); err == nil {
+		t.Errorf("expected an error for an unknown format")
+	}
+}
+
+func TestRunBuild(t *testing.T) {
+	src, out := t.TempDir(), t.TempDir()
+	data := filepath.Join(t.TempDir(), "data.json")
+	os.WriteFile(filepath.Join(src, "index.haml"), []byte("%p= title\n%br"), 0644)
+	os.WriteFile(data, []byte(`{"title": "one"}`), 0644)
+	read := func() string {
+		b, _ := os.ReadFile(filepath.Join(out, "index.html"))
+		return string(b)
+	}
+
+	if err := runBuild([]string{"-data", data, "-format", "html", src, out}); err != nil {
+		t.Fatal(err)
+	}
+	if output := read(); output != "<p>one</p>\n<br>" {
+		t.Errorf("unexpected page %q", output)
+	}
+
+	// a newer data file renders the page again.
+	later := time.Now().Add(time.Hour)
+	os.WriteFile(data, []byte(`{"title": "two"}`), 0644)
+	os.Chtimes(data, later, later)
+	if err := runBuild([]string{"-data", data, src, out}); err != nil {
+		t.Fatal(err)
+	}
+	if output := read(); output != "<p>two</p>\n<br />" {
+		t.Errorf("unexpected page %q", output)
+	}
+
+	// so does -force, once the page is newer than the data.
+	earlier := time.Now().Add(-time.Hour)
+	os.Chtimes(data, earlier, earlier)
+	if err := runBuild([]string{"-data", data, "-format", "html", src, out}); err != nil {
+		t.Fatal(err)
+	}
+	if output := read(); output != "<p>two</p>\n<br />" {
+		t.Errorf("expected the page to be kept, got %q", output)
+	}
+	if err := runBuild([]string{"-data", data, "-force", "-indent", "2", "-no-autoclose", src, out}); err != nil {
+		t.Fatal(err)
+	}
+	if output := read(); output != "<p>two</p>\n<br>" {
+		t.Errorf("unexpected page %q", output)
+	}
+
+	if err := runBuild([]string{"-format", "pdf", src, out}); err == nil {
+		t.Errorf("expected an error for an unknown format")
+	}
+}
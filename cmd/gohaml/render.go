@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/realistschuckle/gohaml"
+)
+
+// runRender writes the markup of a template to the standard output:
+//
+//	gohaml render page.haml --data data.json
+func runRender(args []string) (err error) {
+	fs := newFlagSet("render")
+	data := fs.String("data", "", "JSON or YAML file holding the scope (- reads JSON from the standard input)")
+	ef := addEngineFlags(fs)
+	files, err := parseArgs(fs, args)
+	if err != nil {
+		return
+	}
+
+	if len(files) != 1 {
+		fs.Usage()
+		return errUsage
+	}
+	scope := map[string]interface{}{}
+	if *data != "" {
+		if scope, err = loadScope(*data); err != nil {
+			return
+		}
+	}
+
+	var engine *gohaml.Engine
+	if engine, err = loadEngine(files[0]); err != nil {
+		return
+	}
+	if err = ef.apply(engine); err != nil {
+		return
+	}
+	_, err = fmt.Fprintln(os.Stdout, engine.Render(scope))
+	return
+}
+
+// loadEngine loads a template through a loader rooted at its directory, so
+// that it shares mixins the way templates served by NewHamlHandler do.
+func loadEngine(file string) (engine *gohaml.Engine, err error) {
+	var loader gohaml.Loader
+	if loader, err = gohaml.NewFileSystemLoader(filepath.Dir(file)); err != nil {
+		return
+	}
+	return loader.Load(filepath.Base(file))
+}
+
+// loadScope reads a scope from a JSON file, or from a YAML file when the
+// name ends in .yaml or .yml. YAML outside the subset parseYAML reads is
+// an error.
+func loadScope(name string) (scope map[string]interface{}, err error) {
+	var b []byte
+	if name == "-" {
+		b, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		b, err = ioutil.ReadFile(name)
+	}
+	if err != nil {
+		return
+	}
+
+	var value interface{}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml":
+		value, err = parseYAML(string(b))
+	default:
+		err = json.Unmarshal(b, &value)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+	scope, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, errors.New(name + ": the scope must be a mapping")
+	}
+	return
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseYAML reads the subset of YAML that is useful for template scopes:
+// block mappings and sequences, plain, single- and double-quoted scalars,
+// flow sequences of scalars and comments. Anchors, aliases, tags, block and
+// multi-line scalars, flow mappings, merge keys and multiple documents are
+// not supported, and are reported as errors rather than misread.
+func parseYAML(src string) (value interface{}, err error) {
+	var lines []yamlLine
+	for i, text := range strings.Split(src, "\n") {
+		text = stripYAMLComment(strings.TrimRight(text, " \t\r"))
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" {
+			continue
+		}
+		if trimmed == "---" || trimmed == "..." || strings.HasPrefix(trimmed, "%") {
+			if trimmed == "---" && len(lines) == 0 {
+				continue
+			}
+			return nil, fmt.Errorf("yaml: line %d: multiple documents and directives are not supported", i+1)
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("yaml: line %d: tabs are not allowed for indentation", i+1)
+		}
+		lines = append(lines, yamlLine{i + 1, len(text) - len(trimmed), trimmed})
+	}
+	if len(lines) == 0 {
+		return map[string]interface{}{}, nil
+	}
+	p := &yamlParser{lines: lines}
+	if value, err = p.block(lines[0].indent); err != nil {
+		return
+	}
+	if p.pos < len(p.lines) {
+		err = fmt.Errorf("yaml: line %d: unexpected indentation", p.lines[p.pos].number)
+	}
+	return
+}
+
+type yamlLine struct {
+	number int
+	indent int
+	text   string
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+func (self *yamlParser) block(indent int) (interface{}, error) {
+	if isSequenceItem(self.lines[self.pos].text) {
+		return self.sequence(indent)
+	}
+	return self.mapping(indent)
+}
+
+func (self *yamlParser) sequence(indent int) (interface{}, error) {
+	var seq []interface{}
+	for self.pos < len(self.lines) {
+		line := self.lines[self.pos]
+		if line.indent != indent || !isSequenceItem(line.text) {
+			break
+		}
+		rest := strings.TrimLeft(strings.TrimPrefix(line.text, "-"), " ")
+		if rest == "" {
+			self.pos++
+			item, err := self.nested(indent, false)
+			if err != nil {
+				return nil, err
+			}
+			seq = append(seq, item)
+			continue
+		}
+		// an item such as "- name: x" starts a mapping indented past the dash.
+		inner := indent + len(line.text) - len(rest)
+		if _, _, ok := splitYAMLKey(rest); ok || isSequenceItem(rest) {
+			self.lines[self.pos] = yamlLine{line.number, inner, rest}
+			item, err := self.block(inner)
+			if err != nil {
+				return nil, err
+			}
+			seq = append(seq, item)
+			continue
+		}
+		value, err := parseYAMLScalar(rest, line.number)
+		if err != nil {
+			return nil, err
+		}
+		seq = append(seq, value)
+		self.pos++
+	}
+	return seq, nil
+}
+
+func (self *yamlParser) mapping(indent int) (interface{}, error) {
+	m := make(map[string]interface{})
+	for self.pos < len(self.lines) {
+		line := self.lines[self.pos]
+		if line.indent != indent || isSequenceItem(line.text) {
+			break
+		}
+		key, rest, ok := splitYAMLKey(line.text)
+		if !ok {
+			return nil, fmt.Errorf("yaml: line %d: expected a key", line.number)
+		}
+		if key == "<<" || strings.HasPrefix(line.text, "?") {
+			return nil, fmt.Errorf("yaml: line %d: merge and complex keys are not supported", line.number)
+		}
+		if _, dup := m[key]; dup {
+			return nil, fmt.Errorf("yaml: line %d: duplicate key %s", line.number, key)
+		}
+		self.pos++
+		if rest != "" {
+			value, err := parseYAMLScalar(rest, line.number)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = value
+			continue
+		}
+		value, err := self.nested(indent, true)
+		if err != nil {
+			return nil, err
+		}
+		m[key] = value
+	}
+	return m, nil
+}
+
+// nested parses the block following a key or dash without a value. A
+// mapping value may be a sequence at the indentation of its key.
+func (self *yamlParser) nested(indent int, sameIndentSequence bool) (interface{}, error) {
+	if self.pos >= len(self.lines) {
+		return nil, nil
+	}
+	next := self.lines[self.pos]
+	if next.indent > indent || (sameIndentSequence && next.indent == indent && isSequenceItem(next.text)) {
+		return self.block(next.indent)
+	}
+	return nil, nil
+}
+
+func isSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func splitYAMLKey(text string) (key string, rest string, ok bool) {
+	if strings.HasPrefix(text, "\"") || strings.HasPrefix(text, "'") {
+		end := strings.IndexByte(text[1:], text[0])
+		if end < 0 || !strings.HasPrefix(text[end+2:], ":") {
+			return
+		}
+		key, rest = text[1:end+1], text[end+3:]
+	} else {
+		i := strings.Index(text, ": ")
+		if i < 0 {
+			if !strings.HasSuffix(text, ":") {
+				return
+			}
+			i = len(text) - 1
+		}
+		key, rest = text[:i], text[i+1:]
+	}
+	if rest != "" && rest[0] != ' ' {
+		return
+	}
+	return key, strings.TrimSpace(rest), true
+}
+
+func stripYAMLComment(text string) string {
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || text[i-1] == ' ' || text[i-1] == '\t'):
+			return strings.TrimRight(text[:i], " \t")
+		}
+	}
+	return text
+}
+
+func parseYAMLScalar(text string, number int) (interface{}, error) {
+	switch {
+	case strings.HasPrefix(text, "\""):
+		s, err := strconv.Unquote(text)
+		if err != nil {
+			return nil, fmt.Errorf("yaml: line %d: invalid quoted string %s", number, text)
+		}
+		return s, nil
+	case strings.HasPrefix(text, "'"):
+		if len(text) < 2 || !strings.HasSuffix(text, "'") {
+			return nil, fmt.Errorf("yaml: line %d: invalid quoted string %s", number, text)
+		}
+		return strings.Replace(text[1:len(text)-1], "''", "'", -1), nil
+	case strings.HasPrefix(text, "["):
+		if !strings.HasSuffix(text, "]") {
+			return nil, fmt.Errorf("yaml: line %d: unterminated sequence %s", number, text)
+		}
+		seq := []interface{}{}
+		if inner := strings.TrimSpace(text[1 : len(text)-1]); inner != "" {
+			items, err := splitYAMLFlow(inner, number)
+			if err != nil {
+				return nil, err
+			}
+			for _, item := range items {
+				value, err := parseYAMLScalar(strings.TrimSpace(item), number)
+				if err != nil {
+					return nil, err
+				}
+				seq = append(seq, value)
+			}
+		}
+		return seq, nil
+	case text == "{}":
+		return map[string]interface{}{}, nil
+	case strings.HasPrefix(text, "{"):
+		return nil, fmt.Errorf("yaml: line %d: flow mappings are not supported", number)
+	case text == "" || strings.ContainsRune("&*!|>@`", rune(text[0])):
+		return nil, fmt.Errorf("yaml: line %d: anchors, aliases, tags and block scalars are not supported: %s", number, text)
+	}
+	switch text {
+	case "~", "null", "Null", "NULL":
+		return nil, nil
+	case "true", "True", "TRUE":
+		return true, nil
+	case "false", "False", "FALSE":
+		return false, nil
+	}
+	if i, err := strconv.Atoi(text); err == nil {
+		return i, nil
+	}
+	if f, err := strconv.ParseFloat(text, 64); err == nil {
+		return f, nil
+	}
+	return text, nil
+}
+
+// splitYAMLFlow splits the items of a flow sequence at the commas outside
+// quotes. Nested collections are not supported.
+func splitYAMLFlow(inner string, number int) (items []string, err error) {
+	var quote byte
+	start := 0
+	for i := 0; i < len(inner); i++ {
+		switch c := inner[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '{':
+			return nil, fmt.Errorf("yaml: line %d: nested flow collections are not supported", number)
+		case c == ',':
+			items = append(items, inner[start:i])
+			start = i + 1
+		}
+	}
+	return append(items, inner[start:]), nil
+}
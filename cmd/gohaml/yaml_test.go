@@ -0,0 +1,52 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+type yamlcase struct {
+	input    string
+	expected interface{}
+}
+
+var yamlTests = []yamlcase{
+	yamlcase{"", map[string]interface{}{}},
+	yamlcase{"title: Hello # comment\ncount: 3\nratio: 0.5\nok: true\nnone: ~", map[string]interface{}{
+		"title": "Hello", "count": 3, "ratio": 0.5, "ok": true, "none": nil}},
+	yamlcase{"quoted: \"a # b\"\nsingle: 'it''s'", map[string]interface{}{"quoted": "a # b", "single": "it's"}},
+	yamlcase{"user:\n  name: Jane\n  tags: [a, b]", map[string]interface{}{
+		"user": map[string]interface{}{"name": "Jane", "tags": []interface{}{"a", "b"}}}},
+	yamlcase{"items:\n  - name: one\n    price: 3\n  - name: two\n    price: 4", map[string]interface{}{
+		"items": []interface{}{
+			map[string]interface{}{"name": "one", "price": 3},
+			map[string]interface{}{"name": "two", "price": 4}}}},
+	yamlcase{"list:\n- a\n- b\nnext: c", map[string]interface{}{"list": []interface{}{"a", "b"}, "next": "c"}},
+	yamlcase{"- - 1\n  - 2\n-\n  k: v", []interface{}{[]interface{}{1, 2}, map[string]interface{}{"k": "v"}}},
+	yamlcase{"---\ntags: [\"a, b\", c]", map[string]interface{}{"tags": []interface{}{"a, b", "c"}}},
+}
+
+func TestParseYAML(t *testing.T) {
+	for i, io := range yamlTests {
+		value, err := parseYAML(io.input)
+		if err != nil {
+			t.Errorf("(%d) Input %q\nunexpected error %s", i, io.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(value, io.expected) {
+			t.Errorf("(%d) Input    %q\nexpected %#v\ngot      %#v", i, io.input, io.expected, value)
+		}
+	}
+}
+
+func TestParseYAMLErrors(t *testing.T) {
+	for _, input := range []string{
+		"key: \"open", "just text", "a: 1\n  b: 2",
+		"base: &b 1\nother: *b", "t: !!str 3", "text: |\n  line", "text: >\n  line",
+		"a: 1\n---\nb: 2", "m: {a: 1}", "s: [a, [b]]", "a: 1\na: 2", "<<: x", "? key",
+	} {
+		if _, err := parseYAML(input); err == nil {
+			t.Errorf("Input %q\nexpected an error", input)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package gohaml
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"strconv"
+	"strings"
+)
+
+/*
+CompressionOptions turns on the compression of the pages sent by the handler returned
+by NewHamlHandlerWithOptions.
+
+Pages are compressed with gzip or deflate, in the zlib format HTTP uses for it, when
+the Accept-Encoding header of the request allows it, preferring gzip, and are sent with
+a Vary: Accept-Encoding header either way. Each encoding has its own ETag. Cached pages
+keep their compressed forms.
+
+The MinSize field contains the size in bytes below which pages are sent as they are;
+zero means 1024.
+
+The Level field contains the compression level, as in compress/flate; zero means
+flate.DefaultCompression.
+*/
+type CompressionOptions struct {
+	MinSize int
+	Level   int
+}
+
+// encodings are the content codings the handler can apply, by preference.
+var encodings = []string{"gzip", "deflate"}
+
+// negotiateEncoding returns the preferred encoding accepted by an
+// Accept-Encoding header, or "" to send the page as it is.
+func negotiateEncoding(header string) (encoding string) {
+	accepted := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			var err error
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				q = 0
+			}
+		}
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			accepted[name] = q
+		}
+	}
+	best := 0.0
+	for _, e := range encodings {
+		q, ok := accepted[e]
+		if !ok {
+			q = accepted["*"]
+		}
+		if q > best {
+			encoding, best = e, q
+		}
+	}
+	return
+}
+
+// encode compresses a page with the given encoding.
+func encode(body string, encoding string, level int) string {
+	if level == 0 {
+		level = flate.DefaultCompression
+	}
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	var err error
+	if encoding == "gzip" {
+		w, err = gzip.NewWriterLevel(&buf, level)
+	} else {
+		// deflate is the zlib format of RFC 1950, not a raw deflate stream.
+		w, err = zlib.NewWriterLevel(&buf, level)
+	}
+	if err != nil {
+		// an invalid level
+		return encode(body, encoding, flate.DefaultCompression)
+	}
+	io.WriteString(w, body)
+	w.Close()
+	return buf.String()
+}
+
+// variantTag returns the ETag of a page sent with an encoding.
+func variantTag(tag string, encoding string) string {
+	return strings.TrimSuffix(tag, "\"") + "-" + encoding + "\""
+}
+
+// encoded returns the page compressed with an encoding, compressing it the
+// first time it is asked for.
+func (p *page) encoded(encoding string, level int) string {
+	p.Lock()
+	defer p.Unlock()
+	if body, ok := p.variants[encoding]; ok {
+		return body
+	}
+	if p.variants == nil {
+		p.variants = make(map[string]string)
+	}
+	p.variants[encoding] = encode(p.body, encoding, level)
+	return p.variants[encoding]
+}
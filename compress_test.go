@@ -0,0 +1,133 @@
+package gohaml
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var encodingTests = []testcase{
+	testcase{"", ""},
+	testcase{"gzip", "gzip"},
+	testcase{"deflate, gzip", "gzip"},
+	testcase{"deflate", "deflate"},
+	testcase{"gzip;q=0.5, deflate", "deflate"},
+	testcase{"gzip;q=0, deflate;q=0", ""},
+	testcase{"br, identity", ""},
+	testcase{"*", "gzip"},
+	testcase{"*, gzip;q=0", "deflate"},
+	testcase{" GZIP ; q=1.0", "gzip"},
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	for i, io := range encodingTests {
+		if output := negotiateEncoding(io.input); output != io.expected {
+			t.Errorf("(%d) Input    %q\nexpected %q\ngot      %q", i, io.input, io.expected, output)
+		}
+	}
+}
+
+func decode(t *testing.T, encoding string, body io.Reader) string {
+	var r io.Reader
+	switch encoding {
+	case "gzip":
+		zr, err := gzip.NewReader(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = zr
+	case "deflate":
+		zr, err := zlib.NewReader(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = zr
+	default:
+		r = body
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestHandlerCompression(t *testing.T) {
+	long := strings.Repeat("%p lorem ipsum\n", 100)
+	dir := writeTemplates(t, map[string]string{"long.haml": long, "short.haml": "%p short", "dynamic.haml": long + "= n"})
+	h, renders := countingHandler(t, dir, &CacheOptions{Key: requestURI})
+	h.opts.Compression = &CompressionOptions{MinSize: 100}
+	expected := strings.TrimSuffix(strings.Repeat("<p>lorem ipsum</p>\n", 100), "\n")
+
+	tags := make(map[string]bool)
+	for _, encoding := range []string{"gzip", "deflate", ""} {
+		req := httptest.NewRequest("GET", "/long.html", nil)
+		req.Header.Set("Accept-Encoding", encoding)
+		w := serve(t, h, req)
+		switch {
+		case w.Header().Get("Content-Encoding") != encoding:
+			t.Errorf("expected Content-Encoding %q, got %q", encoding, w.Header().Get("Content-Encoding"))
+		case w.Header().Get("Vary") != "Accept-Encoding":
+			t.Errorf("expected Vary: Accept-Encoding, got %q", w.Header().Get("Vary"))
+		case encoding != "" && w.Body.Len() >= len(expected)/4:
+			t.Errorf("expected %s to compress the page, got %d bytes", encoding, w.Body.Len())
+		case w.Header().Get("Content-Length") != "" && w.Header().Get("Content-Length") != strconv.Itoa(w.Body.Len()):
+			t.Errorf("Content-Length %s does not match the %d bytes sent", w.Header().Get("Content-Length"), w.Body.Len())
+		}
+		if output := decode(t, encoding, w.Body); output != expected {
+			t.Errorf("%s: unexpected page %q", encoding, output)
+		}
+		tags[w.Header().Get("ETag")] = true
+
+		// conditional requests are answered in the encoding the client has.
+		cond := httptest.NewRequest("GET", "/long.html", nil)
+		cond.Header.Set("Accept-Encoding", encoding)
+		cond.Header.Set("If-None-Match", w.Header().Get("ETag"))
+		if w2 := serve(t, h, cond); w2.Code != http.StatusNotModified || w2.Header().Get("ETag") != w.Header().Get("ETag") {
+			t.Errorf("%s: expected 304 with ETag %q, got %d %q", encoding, w.Header().Get("ETag"), w2.Code, w2.Header().Get("ETag"))
+		}
+	}
+	if len(tags) != 3 {
+		t.Errorf("expected an ETag for each encoding, got %v", tags)
+	}
+
+	req := httptest.NewRequest("GET", "/short.html", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	if w := serve(t, h, req); w.Header().Get("Content-Encoding") != "" || w.Body.String() != "<p>short</p>" {
+		t.Errorf("expected a short page to be sent as it is, got %q %q", w.Header().Get("Content-Encoding"), w.Body.String())
+	}
+
+	// cached pages keep their compressed forms.
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest("GET", "/dynamic.html", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		w := serve(t, h, req)
+		if output := decode(t, "gzip", w.Body); output != expected+"\n1" {
+			t.Errorf("unexpected page %q", output)
+		}
+	}
+	if *renders != 1 {
+		t.Errorf("expected 1 render, got %d", *renders)
+	}
+	cached := h.cache.get("/dynamic.html", h.cache.entries["/dynamic.html"].Value.(*cacheEntry).version)
+	if _, ok := cached.variants["gzip"]; !ok || len(cached.variants) != 1 {
+		t.Errorf("expected the cached page to keep its gzip form, got %v", cached.variants)
+	}
+
+	// without the cache, conditional requests for static pages are
+	// answered before rendering.
+	h, _ = countingHandler(t, dir, nil)
+	h.opts.Compression = &CompressionOptions{MinSize: 100}
+	req = httptest.NewRequest("GET", "/long.html", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	tag := serve(t, h, req).Header().Get("ETag")
+	req.Header.Set("If-None-Match", tag)
+	if w := serve(t, h, req); w.Code != http.StatusNotModified || w.Header().Get("ETag") != tag || w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Errorf("expected 304 with ETag %q, got %d %q", tag, w.Code, w.Header().Get("ETag"))
+	}
+}
@@ -0,0 +1,221 @@
+package gohaml
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Format rewrites a HAML template in canonical form: two spaces of
+// indentation per level, div omitted before #id and .class shorthands,
+// attribute hashes written as {:key => "value", :other => path} and code
+// written as "- code". Comments are kept; blank lines are not. The rendered
+// output of the template does not change.
+func Format(src []byte) (output []byte, err error) {
+	var t *tree
+	if t, err = parser.parse(string(src)); err != nil {
+		return
+	}
+	f := new(formatter)
+	if err = f.nodes(t.nodes, ""); err != nil {
+		return
+	}
+	output = f.buf.Bytes()
+	return
+}
+
+type formatter struct {
+	buf bytes.Buffer
+}
+
+func (self *formatter) line(indent string, s string) {
+	self.buf.WriteString(indent)
+	self.buf.WriteString(s)
+	self.buf.WriteString("\n")
+}
+
+func (self *formatter) nodes(nodes []inode, indent string) (err error) {
+	for _, n := range nodes {
+		if err = self.node(n, indent); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (self *formatter) node(n inode, indent string) (err error) {
+	var children []inode
+	switch n := n.(type) {
+	case *node:
+		var s string
+		if s, err = formatTag(n); err != nil {
+			return
+		}
+		self.line(indent, s)
+		children = n._children
+	case *rangenode:
+		target := n._rhs.value
+		if !n._rhs.needsResolution {
+			target = formatLiteral(n._count)
+		}
+		switch {
+		case n._lhs2 != "":
+			self.line(indent, fmt.Sprintf("- for %s, %s := range %s", n._lhs1, n._lhs2, target))
+		case n._lhs1 != "":
+			self.line(indent, fmt.Sprintf("- for %s := range %s", n._lhs1, target))
+		default:
+			self.line(indent, "- for range "+target)
+		}
+		children = n._children
+	case *declassnode:
+		self.line(indent, fmt.Sprintf("- %s := %s", n._lhs, formatLiteral(n._rhs)))
+		children = n._children
+	case *vdeclassnode:
+		self.line(indent, fmt.Sprintf("- %s := %s", n._lhs, n._rhs.value))
+		children = n._children
+	case *defnode:
+		if len(n._params) > 0 {
+			self.line(indent, fmt.Sprintf("- def %s(%s)", n._name, strings.Join(n._params, ", ")))
+		} else {
+			self.line(indent, "- def "+n._name)
+		}
+		children = n._children
+	case *callnode:
+		if len(n._args) > 0 {
+			var args []string
+			for _, arg := range n._args {
+				if arg._path.needsResolution {
+					args = append(args, arg._path.value)
+				} else {
+					args = append(args, formatLiteral(arg._atom))
+				}
+			}
+			self.line(indent, fmt.Sprintf("+%s(%s)", n._name, strings.Join(args, ", ")))
+		} else {
+			self.line(indent, "+"+n._name)
+		}
+		children = n._children
+	case *yieldnode:
+		self.line(indent, "- yield")
+	case *commentnode:
+		self.line(indent, strings.TrimRight("-# "+n._text, " "))
+		children = n._children
+	default:
+		return fmt.Errorf("gohaml: cannot format %T", n)
+	}
+	return self.nodes(children, indent+"  ")
+}
+
+func formatTag(n *node) (output string, err error) {
+	if n._name == "doctype" {
+		output = "!!!" + n._remainder.value
+		return
+	}
+
+	var tag bytes.Buffer
+	var shorthand []string
+	hash := n._attrs
+	for i, attr := range n._attrs {
+		if attr.key.needsResolution || attr.value.needsResolution || !isHamlName(attr.value.value) {
+			break
+		}
+		if attr.key.value == "id" && i == 0 {
+			shorthand = append(shorthand, "#"+attr.value.value)
+		} else if attr.key.value == "class" {
+			shorthand = append(shorthand, "."+attr.value.value)
+		} else {
+			break
+		}
+		hash = n._attrs[i+1:]
+	}
+
+	remainder := n._remainder.value
+	hasRemainder := n._remainder.needsResolution || len(remainder) > 0
+	if !hasRemainder && len(hash) == 0 && len(shorthand) > 0 && (n._noNewline || n._autoclose) {
+		// "<" and "/" cannot follow a shorthand, so keep the last attribute
+		// in the hash where they can.
+		shorthand = shorthand[:len(shorthand)-1]
+		hash = n._attrs[len(shorthand):]
+	}
+
+	switch {
+	case len(n._name) == 0 && len(n._attrs) == 0:
+		// plain text
+	case len(shorthand) > 0 && (n._name == "div" || n._name == ""):
+	case n._name == "":
+		tag.WriteString("%div")
+	default:
+		tag.WriteString("%" + n._name)
+	}
+	tag.WriteString(strings.Join(shorthand, ""))
+
+	if len(hash) > 0 {
+		var attrs []string
+		for _, attr := range hash {
+			var s string
+			if s, err = formatAttr(attr); err != nil {
+				return
+			}
+			attrs = append(attrs, s)
+		}
+		tag.WriteString("{" + strings.Join(attrs, ", ") + "}")
+	}
+
+	switch {
+	case n._remainder.needsResolution:
+		tag.WriteString("= " + remainder)
+	case len(remainder) > 0 && tag.Len() == 0:
+		tag.WriteString(hamlText(remainder))
+	case len(remainder) > 0:
+		tag.WriteString(" " + remainder)
+	}
+	switch {
+	case n._noNewline:
+		tag.WriteString("<")
+	case n._autoclose && !hasRemainder:
+		tag.WriteString("/")
+	}
+	output = tag.String()
+	return
+}
+
+func formatAttr(attr *resPair) (output string, err error) {
+	key, value := attr.key.value, attr.value.value
+	if attr.isSplat() {
+		output = value
+		return
+	}
+	if strings.ContainsAny(key, ",}= \t") || strings.ContainsAny(value, ",}") {
+		err = fmt.Errorf("gohaml: cannot format attribute %s => %q", key, value)
+		return
+	}
+	if !attr.key.needsResolution {
+		key = ":" + key
+	}
+	if !attr.value.needsResolution && value != "true" && value != "false" {
+		value = "\"" + value + "\""
+	}
+	output = key + " => " + value
+	return
+}
+
+// formatLiteral writes a value scanned by the code lexer back as Go source.
+func formatLiteral(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		// the lexer keeps escapes as written, so only an unescaped quote
+		// needs the raw string form.
+		if strings.Contains(strings.Replace(v, "\\\"", "", -1), "\"") && !strings.Contains(v, "`") {
+			return "`" + v + "`"
+		}
+		return "\"" + v + "\""
+	case float64:
+		s := strconv.FormatFloat(v, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".eIN") {
+			s += ".0"
+		}
+		return s
+	}
+	return fmt.Sprint(v)
+}
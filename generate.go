@@ -0,0 +1,659 @@
+package gohaml
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ImportPath is the import path used by the code that Generate produces to
+// reach the runtime helpers of this package.
+const ImportPath = "github.com/realistschuckle/gohaml"
+
+/*
+GenerateOptions controls the Go source produced by Engine.Generate.
+
+The Package field contains the name of the package the generated file belongs to.
+
+The Func field contains the name of the generated render function.
+
+The Type field contains the Go type of the data parameter of the render function, for
+example "*UserPage". Top-level names used in the template are looked up as fields of
+that value, so the type has to be a struct or a pointer to one.
+*/
+type GenerateOptions struct {
+	Package string
+	Func    string
+	Type    string
+}
+
+/*
+Generate writes a Go source file containing a render function for the template of the
+following form.
+
+	func RenderUserPage(w io.Writer, data *UserPage) error
+
+The function writes the same markup as Render does for a scope holding the fields of
+data, using the Indentation and Autoclose settings of the engine at the time Generate
+is called. Static markup is written as precomputed chunks and the paths used in the
+template are compiled as Go selector expressions, so every segment of a path has to be
+a field of the value it is applied to. Fields that FieldLookup hides from Render by
+their tags are not hidden from the generated code.
+
+Mixins are expanded where they are called; recursive mixins cannot be generated.
+Loops follow the rules of Go's range statement for the type of the value they range
+over, so a range with two variables cannot be over a channel, an iter.Seq or an integer
+other than a literal one, a range with one variable binds the index of a slice or
+string, and a range over a string binds runes rather than one-character strings. Maps
+are visited in the order of their keys, as Render does. The loop value describing the
+current iteration is not available, and neither are EscapeHTML, FieldLookup and the
+Formatters set with SetFormatter.
+*/
+func (self *Engine) Generate(w io.Writer, opts GenerateOptions) (err error) {
+	g := &generator{r: &renderState{indent: self.Indentation, autoclose: self.Autoclose, mixins: self.mixins, shared: self.shared}}
+	g.pushFrame()
+	if err = g.nodes(self.ast.nodes, ""); err != nil {
+		return
+	}
+	if g.usesLoop {
+		return fmt.Errorf("gohaml: loop is not supported in generated code")
+	}
+	if self.EscapeHTML {
+		return fmt.Errorf("gohaml: EscapeHTML is not supported in generated code")
+	}
+	if self.FieldLookup != 0 {
+		return fmt.Errorf("gohaml: FieldLookup is not supported in generated code")
+	}
+	if len(self.formatters) > 0 {
+		return fmt.Errorf("gohaml: formatters are not supported in generated code")
+	}
+	body := g.popFrame()
+
+	var src bytes.Buffer
+	src.WriteString("// Code generated by gohaml. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&src, "package %s\n\n", opts.Package)
+	src.WriteString("import (\n\t\"bytes\"\n\t\"io\"\n")
+	if g.usesRuntime {
+		fmt.Fprintf(&src, "\n\t%q\n", ImportPath)
+	}
+	src.WriteString(")\n\n")
+	fmt.Fprintf(&src, "func %s(w io.Writer, data %s) error {\n", opts.Func, opts.Type)
+	src.WriteString("var buf bytes.Buffer\n_ = data\n")
+	src.Write(body)
+	src.WriteString("_, err := buf.WriteTo(w)\nreturn err\n}\n")
+
+	var out []byte
+	if out, err = format.Source(src.Bytes()); err != nil {
+		return fmt.Errorf("gohaml: generated invalid source: %s", err)
+	}
+	_, err = w.Write(out)
+	return
+}
+
+// FormatValue converts a value to the text that Render outputs for it when
+// no Formatter is set for its type. It is used by the code produced by
+// Generate.
+func FormatValue(v interface{}) string {
+	return formatValue(reflect.ValueOf(v), nil)
+}
+
+// SplatAttrs returns the keys and values of a map, or the names and values of
+// the fields of a struct, the way Render expands {attrs}. It is used by the
+// code produced by Generate.
+func SplatAttrs(v interface{}) (pairs []interface{}) {
+	for _, p := range splatAttrs(reflect.ValueOf(v)) {
+		pairs = append(pairs, rangeValue(p))
+	}
+	return
+}
+
+// WriteAttrs writes the attributes given as alternating keys and values the
+// same way Render does. It is used by the code produced by Generate.
+func WriteAttrs(buf *bytes.Buffer, pairs ...interface{}) {
+	values := make([]reflect.Value, len(pairs))
+	for i, v := range pairs {
+		values[i] = reflect.ValueOf(v)
+	}
+	writeAttrs(buf, values, nil, false)
+}
+
+// RangeItem is one iteration of a loop in the code produced by Generate: the
+// key it was produced for and the markup it writes.
+type RangeItem struct {
+	Key  interface{}
+	Body func()
+}
+
+// AddRange runs an iteration of a loop over v right away, unless v is a map,
+// whose iterations are kept for RunRange to put in order. Channels and
+// iterators are thus consumed as the loop runs. It is used by the code
+// produced by Generate.
+func AddRange(v interface{}, items []RangeItem, item RangeItem) []RangeItem {
+	if reflect.ValueOf(v).Kind() == reflect.Map {
+		return append(items, item)
+	}
+	item.Body()
+	return items
+}
+
+// RunRange runs the iterations of a loop over v that AddRange kept, in the
+// order of their keys when v is a map, the way Render visits maps. It is
+// used by the code produced by Generate.
+func RunRange(v interface{}, items []RangeItem) {
+	if reflect.ValueOf(v).Kind() == reflect.Map {
+		sort.SliceStable(items, func(i, j int) bool {
+			return lessValue(reflect.ValueOf(items[i].Key), reflect.ValueOf(items[j].Key))
+		})
+	}
+	for _, item := range items {
+		item.Body()
+	}
+}
+
+// genScope maps the names of a template to the Go variables holding them.
+// Frames are introduced by the function itself and by every expanded mixin,
+// and own the variables declared by assignments.
+type genScope struct {
+	vars  map[string]string
+	frame bool
+	decls []string
+	body  bytes.Buffer
+}
+
+// genCall records the block of an expanded mixin call and the scopes that
+// were visible at the call site.
+type genCall struct {
+	def    *defnode
+	block  []inode
+	scopes []*genScope
+}
+
+type generator struct {
+	r           *renderState
+	scopes      []*genScope
+	calls       []*genCall
+	static      bytes.Buffer
+	usesRuntime bool
+	counter     int
+	loops       int
+	usesLoop    bool
+}
+
+func (self *generator) frame() *genScope {
+	for i := len(self.scopes) - 1; i >= 0; i-- {
+		if self.scopes[i].frame {
+			return self.scopes[i]
+		}
+	}
+	return nil
+}
+
+func (self *generator) out() *bytes.Buffer {
+	return &self.frame().body
+}
+
+func (self *generator) pushFrame() {
+	self.flush()
+	self.scopes = append(self.scopes, &genScope{vars: make(map[string]string), frame: true})
+}
+
+func (self *generator) popFrame() []byte {
+	self.flush()
+	frame := self.scopes[len(self.scopes)-1]
+	self.scopes = self.scopes[:len(self.scopes)-1]
+	var body bytes.Buffer
+	for _, decl := range frame.decls {
+		fmt.Fprintf(&body, "var %s interface{}\n_ = %s\n", decl, decl)
+	}
+	body.Write(frame.body.Bytes())
+	return body.Bytes()
+}
+
+func (self *generator) pushScope() {
+	self.scopes = append(self.scopes, &genScope{vars: make(map[string]string)})
+}
+
+func (self *generator) popScope() {
+	self.scopes = self.scopes[:len(self.scopes)-1]
+}
+
+func (self *generator) text(s string) {
+	self.static.WriteString(s)
+}
+
+func (self *generator) flush() {
+	if self.static.Len() > 0 {
+		fmt.Fprintf(self.out(), "buf.WriteString(%s)\n", strconv.Quote(self.static.String()))
+		self.static.Reset()
+	}
+}
+
+func (self *generator) code(format string, args ...interface{}) {
+	self.flush()
+	fmt.Fprintf(self.out(), format, args...)
+	self.out().WriteString("\n")
+}
+
+// newVar returns a fresh Go identifier for the template name.
+func (self *generator) newVar(name string) string {
+	self.counter++
+	return fmt.Sprintf("h%d_%s", self.counter, name)
+}
+
+func (self *generator) lookup(name string) (string, bool) {
+	for i := len(self.scopes) - 1; i >= 0; i-- {
+		if v, ok := self.scopes[i].vars[name]; ok {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// assign returns the variable for an assignment to name, declaring it in the
+// innermost frame the first time the frame assigns it.
+func (self *generator) assign(name string) string {
+	frame := self.frame()
+	if v, ok := frame.vars[name]; ok {
+		return v
+	}
+	v := self.newVar(name)
+	frame.vars[name] = v
+	frame.decls = append(frame.decls, v)
+	return v
+}
+
+func (self *generator) path(p string) string {
+	keyPath := strings.Split(p, ".")
+	root, ok := self.lookup(keyPath[0])
+	if !ok {
+		self.usesLoop = self.usesLoop || keyPath[0] == "loop" && self.loops > 0
+		root = "data." + keyPath[0]
+	}
+	return strings.Join(append([]string{root}, keyPath[1:]...), ".")
+}
+
+func (self *generator) format(expr string) string {
+	self.usesRuntime = true
+	return "gohaml.FormatValue(" + expr + ")"
+}
+
+func (self *generator) literal(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return strconv.Quote(t)
+	case int:
+		return strconv.Itoa(t)
+	case float64:
+		return "float64(" + strconv.FormatFloat(t, 'g', -1, 64) + ")"
+	}
+	return "nil"
+}
+
+// nodes generates siblings the way tree.resolve renders the top-level nodes.
+func (self *generator) nodes(nodes []inode, curIndent string) (err error) {
+	newline := false
+	for _, n := range rendered(nodes) {
+		if newline && !isAssignment(n) {
+			self.text("\n")
+		}
+		if err = self.node(n, curIndent); err != nil {
+			return
+		}
+		if !isAssignment(n) {
+			newline = !n.noNewline()
+		}
+	}
+	return
+}
+
+// children generates siblings the way resolveChildren renders them.
+func (self *generator) children(children []inode, curIndent string) (err error) {
+	newline := false
+	for _, n := range rendered(children) {
+		if isAssignment(n) {
+			if err = self.node(n, curIndent); err != nil {
+				return
+			}
+			continue
+		}
+		if newline {
+			self.text("\n" + curIndent)
+		}
+		if err = self.node(n, curIndent); err != nil {
+			return
+		}
+		newline = !n.noNewline()
+	}
+	return
+}
+
+func (self *generator) node(n inode, curIndent string) error {
+	switch t := n.(type) {
+	case *node:
+		return self.tag(t, curIndent)
+	case *rangenode:
+		return self.rangeLoop(t, curIndent)
+	case *declassnode:
+		self.code("%s = %s", self.assign(t._lhs), self.literal(t._rhs))
+	case *vdeclassnode:
+		self.code("%s = %s", self.assign(t._lhs), self.format(self.path(t._rhs.value)))
+	case *defnode:
+	case *callnode:
+		return self.call(t, curIndent)
+	case *yieldnode:
+		return self.yield(curIndent)
+	default:
+		return fmt.Errorf("gohaml: cannot generate code for %T", n)
+	}
+	return nil
+}
+
+func (self *generator) tag(n *node, curIndent string) (err error) {
+	if n._name == "doctype" {
+		var buf bytes.Buffer
+		n.resolve(nil, &buf, curIndent, self.r)
+		self.text(buf.String())
+		return
+	}
+	name := n._name
+	if len(n._attrs) > 0 && len(name) == 0 {
+		name = "div"
+	}
+
+	if !n._remainder.needsResolution {
+		remainder := n._remainder.value
+		if len(name) == 0 {
+			self.text(remainder)
+		} else if len(remainder) > 0 {
+			self.text("<" + name)
+			self.attrs(n)
+			self.text(">" + remainder + "</" + name + ">")
+		} else {
+			self.text("<" + name)
+			self.attrs(n)
+			err = self.outputChildren(n, name, curIndent)
+		}
+		return
+	}
+
+	remainder := self.format(self.path(n._remainder.value))
+	if len(name) == 0 {
+		self.code("buf.WriteString(%s)", remainder)
+		return
+	}
+	self.code("if s := %s; len(s) > 0 {", remainder)
+	self.text("<" + name)
+	self.attrs(n)
+	self.text(">")
+	self.code("buf.WriteString(s)")
+	self.text("</" + name + ">")
+	self.code("} else {")
+	self.text("<" + name)
+	self.attrs(n)
+	if err = self.outputChildren(n, name, curIndent); err != nil {
+		return
+	}
+	self.code("}")
+	return
+}
+
+func (self *generator) attrs(n *node) {
+	dynamic := false
+	for _, pair := range n._attrs {
+		dynamic = dynamic || pair.key.needsResolution || pair.value.needsResolution
+	}
+	if !dynamic {
+		var buf bytes.Buffer
+		n.resolveAttrs(nil, &buf, self.r)
+		self.text(buf.String())
+		return
+	}
+	var args []string
+	var splats []string
+	self.usesRuntime = true
+	for _, pair := range n._attrs {
+		if pair.isSplat() {
+			splats = append(splats, self.path(pair.value.value))
+			continue
+		}
+		for _, r := range []res{pair.key, pair.value} {
+			if r.needsResolution {
+				args = append(args, self.path(r.value))
+			} else {
+				args = append(args, strconv.Quote(r.value))
+			}
+		}
+	}
+	if len(splats) == 0 {
+		self.code("gohaml.WriteAttrs(&buf, %s)", strings.Join(args, ", "))
+		return
+	}
+	// splats are expanded at run time, in the place they were written.
+	attrs := self.newVar("attrs")
+	self.code("var %s []interface{}", attrs)
+	i := 0
+	for _, pair := range n._attrs {
+		if pair.isSplat() {
+			self.code("%s = append(%s, gohaml.SplatAttrs(%s)...)", attrs, attrs, splats[0])
+			splats = splats[1:]
+		} else {
+			self.code("%s = append(%s, %s, %s)", attrs, attrs, args[i], args[i+1])
+			i += 2
+		}
+	}
+	self.code("gohaml.WriteAttrs(&buf, %s...)", attrs)
+}
+
+func (self *generator) outputChildren(n *node, name string, curIndent string) (err error) {
+	ind := curIndent + self.r.indent
+	if n._noNewline {
+		ind = curIndent
+	}
+	children := rendered(n._children)
+	if len(children) == 0 {
+		if self.r.autoclose || n._autoclose {
+			self.text(" />")
+		} else {
+			self.text(">")
+		}
+		return
+	}
+	self.text(">")
+	first := true
+	for _, child := range children {
+		if isAssignment(child) {
+			if err = self.node(child, ind); err != nil {
+				return
+			}
+			continue
+		}
+		if !first || !n._noNewline {
+			self.text("\n" + ind)
+		}
+		first = false
+		if err = self.node(child, ind); err != nil {
+			return
+		}
+	}
+	if !n._noNewline {
+		self.text("\n" + curIndent)
+	}
+	self.text("</" + name + ">")
+	return
+}
+
+func (self *generator) rangeLoop(n *rangenode, curIndent string) (err error) {
+	children := rendered(n._children)
+	last := lastWritten(children)
+	separated := last != nil && !last.noNewline()
+	first := self.newVar("first")
+	self.code("{")
+	if separated {
+		self.code("%s := true", first)
+	}
+	self.pushScope()
+	key, value := self.rangeVar(n._lhs1), self.rangeVar(n._lhs2)
+	closing := "}"
+	switch count := n._count.(type) {
+	case int:
+		if isBound(value) {
+			if !isBound(key) {
+				key = self.newVar("i")
+			}
+			self.code("for %s := range %d {", key, count)
+			self.code("%s := %s", value, key)
+		} else {
+			self.code("for %s range %d {", rangeClause(key, ""), count)
+		}
+	case string:
+		if isBound(value) {
+			self.code("for %s range %s {", rangeClause(key, "r"), strconv.Quote(count))
+			self.code("%s := string(r)", value)
+		} else {
+			self.code("for %s range %s {", rangeClause(key, ""), strconv.Quote(count))
+		}
+	case nil:
+		// The iterations over a map are collected first so that RunRange can
+		// run them in the order of their keys.
+		target, items := self.newVar("r"), self.newVar("items")
+		self.usesRuntime = true
+		self.code("%s := %s", target, self.path(n._rhs.value))
+		self.code("var %s []gohaml.RangeItem", items)
+		sortKey := key
+		if !isBound(key) && isBound(value) {
+			sortKey = self.newVar("k")
+			key = sortKey
+		}
+		self.code("for %s range %s {", rangeClause(key, value), target)
+		if isBound(sortKey) {
+			self.code("%s = gohaml.AddRange(%s, %s, gohaml.RangeItem{Key: %s, Body: func() {", items, target, items, sortKey)
+		} else {
+			self.code("%s = gohaml.AddRange(%s, %s, gohaml.RangeItem{Body: func() {", items, target, items)
+		}
+		closing = fmt.Sprintf("}})\n}\ngohaml.RunRange(%s, %s)", target, items)
+	default:
+		self.code("for %s range []int{} {", rangeClause(key, value))
+	}
+	for _, v := range []string{key, value} {
+		if isBound(v) {
+			self.code("_ = %s", v)
+		}
+	}
+	if separated {
+		self.code("if !%s {", first)
+		self.text("\n" + curIndent)
+		self.code("}")
+		self.code("%s = false", first)
+	}
+	self.loops++
+	err = self.children(children, curIndent)
+	self.loops--
+	if err != nil {
+		return
+	}
+	self.code("%s", closing)
+	self.popScope()
+	self.code("}")
+	return
+}
+
+// rangeVar declares the Go variable for a range variable of the template.
+// The blank and absent variables stay as they are.
+func (self *generator) rangeVar(name string) string {
+	if name == "" || name == "_" {
+		return name
+	}
+	v := self.newVar(name)
+	self.scopes[len(self.scopes)-1].vars[name] = v
+	return v
+}
+
+func isBound(v string) bool {
+	return v != "" && v != "_"
+}
+
+// rangeClause returns the variables of a Go range clause, up to and
+// including the :=, binding key and value where they are present.
+func rangeClause(key string, value string) string {
+	switch {
+	case isBound(value):
+		if key == "" {
+			key = "_"
+		}
+		return key + ", " + value + " :="
+	case isBound(key):
+		return key + " :="
+	}
+	return ""
+}
+
+func (self *generator) call(n *callnode, curIndent string) (err error) {
+	def := self.r.lookupMixin(n._name)
+	if def == nil {
+		return fmt.Errorf("gohaml: mixin %s is not defined", n._name)
+	}
+	for _, c := range self.calls {
+		if c.def == def {
+			return fmt.Errorf("gohaml: cannot generate code for recursive mixin %s", n._name)
+		}
+	}
+
+	var args []string
+	for _, arg := range n._args {
+		if arg._path.needsResolution {
+			args = append(args, self.path(arg._path.value))
+		} else {
+			args = append(args, self.literal(arg._atom))
+		}
+	}
+
+	scopes := append([]*genScope(nil), self.scopes...)
+	self.calls = append(self.calls, &genCall{def, n._children, scopes})
+	self.pushFrame()
+	for i, param := range def._params {
+		v := self.newVar(param)
+		self.scopes[len(self.scopes)-1].vars[param] = v
+		if i < len(args) {
+			self.code("%s := %s", v, args[i])
+		} else {
+			self.code("var %s interface{}", v)
+		}
+		self.code("_ = %s", v)
+	}
+	if err = self.children(def._children, curIndent); err != nil {
+		return
+	}
+	body := self.popFrame()
+	self.calls = self.calls[:len(self.calls)-1]
+	self.code("{\n%s}", body)
+	return
+}
+
+func (self *generator) yield(curIndent string) (err error) {
+	if len(self.calls) == 0 {
+		return
+	}
+	c := self.calls[len(self.calls)-1]
+	self.calls = self.calls[:len(self.calls)-1]
+
+	// the block is generated in the scopes of the call site, but its code
+	// still goes to the innermost frame.
+	saved := self.scopes
+	frame := self.frame()
+	self.flush()
+	self.scopes = append(append([]*genScope(nil), c.scopes...), &genScope{vars: make(map[string]string), frame: true})
+	err = self.children(c.block, curIndent)
+	self.flush()
+	inner := self.scopes[len(self.scopes)-1]
+	self.scopes = saved
+	self.calls = append(self.calls, c)
+
+	frame.decls = append(frame.decls, inner.decls...)
+	frame.body.Write(inner.body.Bytes())
+	return
+}
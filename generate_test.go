@@ -0,0 +1,190 @@
+package gohaml
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type genItem struct{ Name string }
+
+type genAuthor struct{ Name string }
+
+const genDataSource = `package main
+
+type genItem struct{ Name string }
+
+type genAuthor struct{ Name string }
+
+type genData struct {
+	Title  string
+	Slug   string
+	Empty  string
+	Items  []genItem
+	Author *genAuthor
+	Tags   []string
+	Flags  map[string]bool
+	Extra  map[string]string
+	Meta   map[string]int
+}
+
+var data = &genData{
+	Title:  "Generated",
+	Slug:   "generated",
+	Items:  []genItem{{"one"}, {"two"}, {"three"}},
+	Author: &genAuthor{"Jane"},
+	Tags:   []string{"b", "a"},
+	Flags:  map[string]bool{"on": true, "off": false},
+	Extra:  map[string]string{"rel": "next", "class": "x"},
+	Meta:   map[string]int{"e": 5, "b": 2, "d": 4, "a": 1, "c": 3, "f": 6},
+}
+`
+
+func genTestScope() map[string]interface{} {
+	return map[string]interface{}{
+		"Title":  "Generated",
+		"Slug":   "generated",
+		"Empty":  "",
+		"Items":  []genItem{{"one"}, {"two"}, {"three"}},
+		"Author": &genAuthor{"Jane"},
+		"Tags":   []string{"b", "a"},
+		"Flags":  map[string]bool{"on": true, "off": false},
+		"Extra":  map[string]string{"rel": "next", "class": "x"},
+		"Meta":   map[string]int{"e": 5, "b": 2, "d": 4, "a": 1, "c": 3, "f": 6},
+	}
+}
+
+var genFixtures = []string{"simple.haml", "test.haml", "mixin_page.haml", "generate.haml"}
+
+func TestGenerateSource(t *testing.T) {
+	engine, _ := NewEngine("%p= Title\n%a{:href => Slug}")
+	var buf bytes.Buffer
+	if err := engine.Generate(&buf, GenerateOptions{"views", "RenderPage", "*Page"}); err != nil {
+		t.Fatalf("couldn't generate: %s", err)
+	}
+	for _, expect := range []string{
+		"package views",
+		"func RenderPage(w io.Writer, data *Page) error {",
+		"gohaml.FormatValue(data.Title)",
+		"gohaml.WriteAttrs(&buf, \"href\", data.Slug)",
+	} {
+		if !strings.Contains(buf.String(), expect) {
+			t.Errorf("expected generated source to contain %q, got\n%s", expect, buf.String())
+		}
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	inputs := []string{
+		"+undefined",
+		"- def loop\n  +loop\n+loop",
+		"- for range 2\n  = loop.Index",
+	}
+	for _, input := range inputs {
+		engine, _ := NewEngine(input)
+		if err := engine.Generate(ioutil.Discard, GenerateOptions{"views", "Render", "*Page"}); err == nil {
+			t.Errorf("Input %q\nexpected an error", input)
+		}
+	}
+
+	engine, _ := NewEngine("= name")
+	engine.EscapeHTML = true
+	if err := engine.Generate(ioutil.Discard, GenerateOptions{"views", "Render", "*Page"}); err == nil {
+		t.Errorf("expected an error for EscapeHTML")
+	}
+
+	engine, _ = NewEngine("= name")
+	engine.SetFormatter(reflect.TypeOf(time.Time{}), func(v interface{}) string { return "" })
+	if err := engine.Generate(ioutil.Discard, GenerateOptions{"views", "Render", "*Page"}); err == nil {
+		t.Errorf("expected an error for a formatter")
+	}
+}
+
+// TestGeneratedMatchesRender compiles the code generated for the fixtures
+// and compares its output with the one of Render.
+func TestGeneratedMatchesRender(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping compilation of generated code in short mode")
+	}
+	gobin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+
+	dir, err := ioutil.TempDir("", "gohaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	pkgDir := filepath.Join(dir, "src", filepath.FromSlash(ImportPath))
+	mainDir := filepath.Join(dir, "src", "gentest")
+	for _, d := range []string{pkgDir, mainDir} {
+		if err = os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = os.MkdirAll(filepath.Join(pkgDir, "ast"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	sources, _ := filepath.Glob("*.go")
+	astSources, _ := filepath.Glob(filepath.Join("ast", "*.go"))
+	for _, src := range append(sources, astSources...) {
+		if strings.HasSuffix(src, "_test.go") {
+			continue
+		}
+		b, err := ioutil.ReadFile(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = ioutil.WriteFile(filepath.Join(pkgDir, src), b, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	loader, _ := NewFileSystemLoader(test_dir)
+
+	var expected []string
+	var main bytes.Buffer
+	main.WriteString("package main\n\nimport (\n\t\"bytes\"\n\t\"fmt\"\n)\n\nfunc main() {\n\tvar buf bytes.Buffer\n")
+	for i, fixture := range genFixtures {
+		engine, err := loader.Load(fixture)
+		if err != nil {
+			t.Fatalf("couldn't load %s: %s", fixture, err)
+		}
+		expected = append(expected, engine.Render(genTestScope()))
+
+		var src bytes.Buffer
+		name := fmt.Sprintf("Render%d", i)
+		if err = engine.Generate(&src, GenerateOptions{"main", name, "*genData"}); err != nil {
+			t.Fatalf("couldn't generate %s: %s", fixture, err)
+		}
+		if err = ioutil.WriteFile(filepath.Join(mainDir, fmt.Sprintf("render%d.go", i)), src.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(&main, "\tbuf.Reset()\n\t%s(&buf, data)\n\tfmt.Printf(\"%%s\\x00\", buf.String())\n", name)
+	}
+	main.WriteString("}\n")
+	ioutil.WriteFile(filepath.Join(mainDir, "main.go"), main.Bytes(), 0644)
+	ioutil.WriteFile(filepath.Join(mainDir, "data.go"), []byte(genDataSource), 0644)
+
+	cmd := exec.Command(gobin, "run", "gentest")
+	cmd.Env = append(os.Environ(), "GOPATH="+dir, "GO111MODULE=off", "GOFLAGS=")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("couldn't run generated code: %s\n%s", err, out)
+	}
+	outputs := strings.Split(string(out), "\x00")
+	for i, fixture := range genFixtures {
+		if outputs[i] != expected[i] {
+			t.Errorf("%s: expected\n%q\ngot\n%q", fixture, expected[i], outputs[i])
+		}
+	}
+}
@@ -1,14 +1,22 @@
 // The gohaml package contains a HAML parser similar to the one found at http://www.haml-lang.com.
 //
-//You can find the specifics about this implementation at http://github.com/realistschuckle/gohaml.
+// You can find the specifics about this implementation at http://github.com/realistschuckle/gohaml.
 package gohaml
 
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/realistschuckle/gohaml/ast"
+)
+
 /*
 Engine provides the template interpretation functionality to convert a HAML template into its
 corresponding tag-based representation.
 
 Available options are:
-  engine.Options["autoclose"] = true|false, default true
+
+	engine.Options["autoclose"] = true|false, default true
 
 The Options field contains the values to modify the way that the engine produces the markup.
 
@@ -16,26 +24,130 @@ The Indentation field contains the string used by the engine to perform indentat
 
 The IncludeCallback field contains the callback invoked by the gohaml engine to process other files
 included through the %include extension.
+
+The EscapeHTML field makes the engine escape the values it looks up in the scope, except for
+values of type template.HTML from html/template, which are written as they are, and leave out
+the attributes whose names from the scope are not valid attribute names. Text written in the
+template itself is never escaped.
+
+The FieldLookup field contains how the names of paths are matched to the fields and methods of
+structs, exactly by default.
 */
 type Engine struct {
 	Autoclose       bool
 	Indentation     string
 	IncludeCallback func(string, map[string]interface{}) string
+	EscapeHTML      bool
+	FieldLookup     FieldLookup
 	ast             *tree
+	mixins          map[string]*defnode
+	shared          *mixinRegistry
+	file            *ast.File
+	formatters      map[reflect.Type]Formatter
 }
 
 // NewEngine returns a new Engine with the given input.
 func NewEngine(input string) (engine *Engine, err error) {
+	var file *ast.File
 	var output *tree
-	output, err = parser.parse(input)
-	if err == nil {
-		engine = &Engine{true, "\t", nil, output}
+	if file, err = Parse(input); err != nil {
+		return
+	}
+	if output, err = fromAST(file); err != nil {
+		return
 	}
+	engine = &Engine{Autoclose: true, Indentation: "\t", ast: output, mixins: output.mixins(), file: file}
 	return
 }
 
-// Render interprets the HAML supplied to the NewEngine method.
+// Render interprets the HAML supplied to the NewEngine method. Calls of
+// undefined mixins, and mixin calls nested deeper than the engine allows,
+// write nothing; RenderValue reports them as errors.
 func (self *Engine) Render(scope map[string]interface{}) (output string) {
-	output = self.ast.resolve(scope, self.Indentation, self.Autoclose)
+	output, _ = self.render(scope, reflect.Value{})
+	return
+}
+
+// RenderValue interprets the HAML supplied to the NewEngine method with the
+// fields and niladic methods of a struct, or of the struct a pointer points
+// to, or the entries of a map with string keys, as the names of the scope.
+// The values the template assigns and the variables of its loops hide those
+// names without changing data. The error reports the first mixin call that
+// could not be rendered.
+func (self *Engine) RenderValue(data interface{}) (output string, err error) {
+	var root reflect.Value
+	if root, err = rootValue(data); err != nil {
+		return
+	}
+	return self.render(make(map[string]interface{}), root)
+}
+
+func (self *Engine) render(scope map[string]interface{}, root reflect.Value) (string, error) {
+	r := &renderState{indent: self.Indentation, autoclose: self.Autoclose, mixins: self.mixins, shared: self.shared, formatters: self.formatters, escape: self.EscapeHTML, root: root, lookup: self.FieldLookup}
+	output := self.ast.resolve(scope, r)
+	return output, r.err
+}
+
+// rootValue checks that data can be rendered by RenderValue. A nil value
+// has no names.
+func rootValue(data interface{}) (root reflect.Value, err error) {
+	root = reflect.ValueOf(data)
+	v := root
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	switch {
+	case !v.IsValid() || v.Kind() == reflect.Ptr:
+		root = reflect.Value{}
+	case v.Kind() == reflect.Struct:
+	case v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String:
+	default:
+		err = fmt.Errorf("gohaml: cannot render %s as a scope", v.Type())
+	}
+	return
+}
+
+// Formatter converts a value to the text that = and attribute values write
+// for it.
+type Formatter func(v interface{}) string
+
+// SetFormatter makes the engine write the values of type t with f, or
+// removes the Formatter for t when f is nil. Values without a Formatter are
+// written with their Error, String or MarshalText method when they have one;
+// time.Time values are written in RFC 3339 unless a Formatter is set for
+// them.
+func (self *Engine) SetFormatter(t reflect.Type, f Formatter) {
+	if f == nil {
+		delete(self.formatters, t)
+		return
+	}
+	if self.formatters == nil {
+		self.formatters = make(map[reflect.Type]Formatter)
+	}
+	self.formatters[t] = f
+}
+
+// Transformer rewrites the syntax tree of a template, for instance to add
+// attributes to some of its tags.
+type Transformer func(file *ast.File) error
+
+// Transform runs the transformers in order on a copy of the syntax tree of
+// the template, and renders the result from then on. If a transformer fails,
+// or leaves nil nodes in the tree, the engine keeps the template as it was
+// before the call.
+func (self *Engine) Transform(transformers ...Transformer) (err error) {
+	file := self.file.Copy()
+	for _, transform := range transformers {
+		if err = transform(file); err != nil {
+			return
+		}
+	}
+	t, err := fromAST(file)
+	if err != nil {
+		return
+	}
+	self.file = file
+	self.ast = t
+	self.mixins = self.ast.mixins()
 	return
 }
@@ -0,0 +1,74 @@
+package gohaml
+
+import "testing"
+
+type inputAttrs struct {
+	Name     string
+	Value    string `haml:",omitempty"`
+	DataID   int    `haml:"data-id"`
+	Required bool
+	Secret   string   `haml:"-"`
+	Classes  []string `haml:"class"`
+	internal string
+}
+
+func attrTestScope() map[string]interface{} {
+	var nilSlice []string
+	return map[string]interface{}{
+		"tags":     []string{"c", "b", "c"},
+		"words":    []string{"b a"},
+		"mixed":    []interface{}{"x", nil, false, 2, []string{"y"}},
+		"flags":    map[string]bool{"on": true, "off": false, "also": true},
+		"anyFlags": map[string]interface{}{"set": "yes", "unset": nil, "no": false},
+		"parts":    []interface{}{"user", 7},
+		"none":     nil,
+		"nilSlice": nilSlice,
+		"empty":    []string{},
+		"no":       false,
+		"yes":      true,
+		"word":     "plain",
+		"extra":    map[string]interface{}{"title": "Hi", "rel": nil, "class": []string{"btn", "a"}},
+		"input":    inputAttrs{Name: "q", DataID: 7, Secret: "s", Classes: []string{"wide"}},
+		"inputPtr": &inputAttrs{Name: "p", Value: "v", Required: true},
+		"dataMap":  map[string]int{"data-b": 2, "data-a": 1},
+	}
+}
+
+var attrTests = []testcase{
+	testcase{"%div.a{:class => tags}", "<div class=\"a b c\" />"},
+	testcase{"%div.d.a{:class => tags}", "<div class=\"a b c d\" />"},
+	testcase{"%div{:class => words}", "<div class=\"a b\" />"},
+	testcase{"%div{:class => mixed}", "<div class=\"2 x y\" />"},
+	testcase{"%div.z{:class => flags}", "<div class=\"also on z\" />"},
+	testcase{"%div{:class => anyFlags}", "<div class=\"set\" />"},
+	testcase{"#x{:id => parts}", "<div id=\"x_user_7\" />"},
+	testcase{"%div{:id => tags}", "<div id=\"c_b_c\" />"},
+	testcase{"%a{:href => none}", "<a />"},
+	testcase{"%a{:href => missing}", "<a />"},
+	testcase{"%a{:class => nilSlice}", "<a />"},
+	testcase{"%a{:class => empty}", "<a />"},
+	testcase{"%a.b{:class => none}", "<a class=\"b\" />"},
+	testcase{"%input{:checked => no}", "<input />"},
+	testcase{"%input{:checked => yes}", "<input checked=\"checked\" />"},
+	testcase{"%a{:rel => tags}", "<a rel=\"c b c\" />"},
+	testcase{"%a.b.a{:class => word}", "<a class=\"a b plain\" />"},
+	testcase{"%a#b{:id => word}", "<a id=\"b_plain\" />"},
+	testcase{"%a{extra}", "<a class=\"a btn\" title=\"Hi\" />"},
+	testcase{"%a.z{:href => word}{extra}", "<a class=\"a btn z\" href=\"plain\" title=\"Hi\" />"},
+	testcase{"%a{:href => word, extra, :rel => \"next\"}", "<a href=\"plain\" class=\"a btn\" rel=\"next\" title=\"Hi\" />"},
+	testcase{"%input{input}", "<input name=\"q\" data-id=\"7\" class=\"wide\" />"},
+	testcase{"%input{ inputPtr }", "<input name=\"p\" value=\"v\" data-id=\"0\" required=\"required\" />"},
+	testcase{"%p{dataMap}", "<p data-a=\"1\" data-b=\"2\" />"},
+	testcase{"%p{none}", "<p />"},
+	testcase{"%p{word}", "<p />"},
+}
+
+func TestAttributeMerging(t *testing.T) {
+	for i, io := range attrTests {
+		engine, _ := NewEngine(io.input)
+		output := engine.Render(attrTestScope())
+		if output != io.expected {
+			t.Errorf("(%d) Input    %q\nexpected %q\ngot      %q", i, io.input, io.expected, output)
+		}
+	}
+}
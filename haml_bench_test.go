@@ -10,10 +10,10 @@ import (
 
 const fn = "test/test.haml"
 
-var template string
+var benchTemplate string
 
 func loadTemplate() {
-	if template != "" {
+	if benchTemplate != "" {
 		return
 	}
 	var (
@@ -34,7 +34,7 @@ func loadTemplate() {
 	if bytes, err = ioutil.ReadAll(reader); err != nil {
 		fmt.Printf("couldn't read testfile: %s (%s)\n", fn, err)
 	}
-	template = string(bytes)
+	benchTemplate = string(bytes)
 }
 
 func BenchmarkRender(b *testing.B) {
@@ -45,7 +45,7 @@ func BenchmarkRender(b *testing.B) {
 	b.StopTimer()
 	loadTemplate()
 
-	if engine, err = NewEngine(template); err != nil {
+	if engine, err = NewEngine(benchTemplate); err != nil {
 		fmt.Printf("couldn't init engine from testfile: %s (%s)\n", fn, err)
 		os.Exit(1)
 	}
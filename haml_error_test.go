@@ -0,0 +1,32 @@
+package gohaml
+
+import "testing"
+
+type errorcase struct {
+	input string
+	line  int
+	msg   string
+}
+
+var syntaxErrorTests = []errorcase{
+	errorcase{"%", 1, "Syntax error on line 1: Invalid tag: .\n"},
+	errorcase{"%p\n  %a{:href}", 2, "Syntax error on line 2: Attribute requires a value.\n"},
+	errorcase{"%p\n%p\n  %a{:href => \"x\"", 3, "Syntax error on line 3: Attributes must have closing '}'.\n"},
+	errorcase{"%p\n  #", 2, "Syntax error on line 2: Illegal element: classes and ids must have values.\n"},
+	errorcase{"%p\n  %a\n\t%b", 3, "Syntax error on line 3: Inconsistent spacing in document changed from space to tab characters.\n"},
+	errorcase{"%p\n- for i := range", 2, "Syntax error on line 2: Did not recognize for i := range (syntax error).\n"},
+}
+
+func TestSyntaxErrors(t *testing.T) {
+	for i, io := range syntaxErrorTests {
+		_, err := NewEngine(io.input)
+		serr, ok := err.(*SyntaxError)
+		if !ok {
+			t.Errorf("(%d) Input %q\nexpected a *SyntaxError, got %#v", i, io.input, err)
+			continue
+		}
+		if serr.Line != io.line || serr.Error() != io.msg {
+			t.Errorf("(%d) Input    %q\nexpected %d %q\ngot      %d %q", i, io.input, io.line, io.msg, serr.Line, serr.Error())
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package gohaml
+
+import (
+	"bytes"
+	"html/template"
+	"strings"
+	"testing"
+)
+
+func escapeTestScope() map[string]interface{} {
+	return map[string]interface{}{
+		"name":    "<b>Tom & Jerry</b>",
+		"safe":    template.HTML("<b>bold</b>"),
+		"link":    template.URL("/search?q=a&lang=en"),
+		"style":   template.CSS("color: red"),
+		"script":  template.JS("alert('hi')"),
+		"quote":   `say "hi"`,
+		"classes": []interface{}{"<x>", template.HTML("y")},
+		"any":     interface{}(template.HTML("<i>any</i>")),
+		"attr":    `x onmouseover="alert(1)"`,
+		"key":     "data-id",
+		"attrs":   map[string]string{"title": "<t>", "a><script>": "x", "b c": "y"},
+	}
+}
+
+var escapeTests = []testcase{
+	testcase{"= name", "&lt;b&gt;Tom &amp; Jerry&lt;/b&gt;"},
+	testcase{"%p= safe", "<p><b>bold</b></p>"},
+	testcase{"= any", "<i>any</i>"},
+	testcase{"%a{:href => link}", "<a href=\"/search?q=a&amp;lang=en\" />"},
+	testcase{"%p{:style => style, :onclick => script}", "<p style=\"color: red\" onclick=\"alert(&#39;hi&#39;)\" />"},
+	testcase{"%p{attr => \"v\"}", "<p />"},
+	testcase{"%p{key => \"v\"}", "<p data-id=\"v\" />"},
+	testcase{"%p{attrs}", "<p title=\"&lt;t&gt;\" />"},
+	testcase{"%p{:title => quote}", "<p title=\"say &#34;hi&#34;\" />"},
+	testcase{"%p{:title => \"<a & b>\"}", "<p title=\"<a & b>\" />"},
+	testcase{"%p{:class => classes}", "<p class=\"&lt;x&gt; y\" />"},
+	testcase{"<i>literal</i>", "<i>literal</i>"},
+	testcase{"- x := name\n= x", "&lt;b&gt;Tom &amp; Jerry&lt;/b&gt;"},
+	testcase{"- x := \"<br>\"\n= x", "<br>"},
+	testcase{"- def card(body)\n  %p= body\n+card(\"<br>\")\n+card(name)", "<p><br></p>\n<p>&lt;b&gt;Tom &amp; Jerry&lt;/b&gt;</p>"},
+}
+
+func TestEscapeHTML(t *testing.T) {
+	for i, io := range escapeTests {
+		engine, _ := NewEngine(io.input)
+		engine.EscapeHTML = true
+		output := engine.Render(escapeTestScope())
+		if output != io.expected {
+			t.Errorf("(%d) Input    %q\nexpected %q\ngot      %q", i, io.input, io.expected, output)
+		}
+	}
+}
+
+func TestEscapeHTMLOff(t *testing.T) {
+	engine, _ := NewEngine("%p{:title => quote}= name")
+	if output := engine.Render(escapeTestScope()); output != `<p title="say "hi"">`+"<b>Tom & Jerry</b></p>" {
+		t.Errorf("expected values to be written as they are, got %q", output)
+	}
+}
+
+func TestFuncMap(t *testing.T) {
+	card, _ := NewEngine("%p.card= name")
+	card.EscapeHTML = true
+	funcs := FuncMap("card", card)
+
+	page := template.Must(template.New("page").Funcs(funcs).Parse(`<div>{{card .}}</div>`))
+	var buf bytes.Buffer
+	if err := page.Execute(&buf, map[string]interface{}{"name": "<Tom>"}); err != nil {
+		t.Fatal(err)
+	}
+	if expected := `<div><p class="card">&lt;Tom&gt;</p></div>`; buf.String() != expected {
+		t.Errorf("expected %q\ngot      %q", expected, buf.String())
+	}
+
+	if html := card.RenderHTML(map[string]interface{}{"name": "a"}); html != template.HTML(`<p class="card">a</p>`) {
+		t.Errorf("unexpected RenderHTML output %q", html)
+	}
+
+	page = template.Must(template.New("page").Funcs(funcs).Parse(`{{card . .}}`))
+	err := page.Execute(&buf, map[string]interface{}{})
+	if err == nil || !strings.Contains(err.Error(), "single scope") {
+		t.Errorf("expected an error for two scopes, got %v", err)
+	}
+}
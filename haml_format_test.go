@@ -0,0 +1,123 @@
+package gohaml
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+var formatTests = []testcase{
+	testcase{"%div.tagClass", ".tagClass\n"},
+	testcase{"%div#tagId.tagClass tag content", "#tagId.tagClass tag content\n"},
+	testcase{"%tag{ :a=>\"b\",:c =>key1 }", "%tag{:a => \"b\", :c => key1}\n"},
+	testcase{"%tag{:id => \"x\", :class => \"y\", :z => true}", "%tag#x.y{:z => true}\n"},
+	testcase{"%tag{:class => \"y\", :id => \"x\"}", "%tag.y{:id => \"x\"}\n"},
+	testcase{"%div{:a => \"b\"}", "%div{:a => \"b\"}\n"},
+	testcase{"%a.button{:href => \"/x\"}<\n    %span Go", "%a.button{:href => \"/x\"}<\n  %span Go\n"},
+	testcase{"%a{:class => \"button\"}<", "%a{:class => \"button\"}<\n"},
+	testcase{"%p\n\t%a\n\t\t%b= key1<", "%p\n  %a\n    %b= key1<\n"},
+	testcase{"%p=key1", "%p= key1\n"},
+	testcase{"%a{:href=>url}{ extra }", "%a{:href => url, extra}\n"},
+	testcase{"=key1", "= key1\n"},
+	testcase{"\\%tag", "\\%tag\n"},
+	testcase{"!!!   5", "!!!   5\n"},
+	testcase{"-for k,v:=range list\n      %p= v", "- for k, v := range list\n  %p= v\n"},
+	testcase{"-for v:=range list\n-for _,v:=range list\n-for   range 3\n-for range \"ab\"", "- for v := range list\n- for _, v := range list\n- for range 3\n- for range \"ab\"\n"},
+	testcase{"-x:=\"say \\\"hi\\\"\"\n-y:=`a \"b\"`\n-z := 2.50\n-w:=key1.Name", "- x := \"say \\\"hi\\\"\"\n- y := `a \"b\"`\n- z := 2.5\n- w := key1.Name\n"},
+	testcase{"-def card( title,body )\n    %p= title\n+card( \"Hi\",post.Body,3 )\n+card()", "- def card(title, body)\n  %p= title\n+card(\"Hi\", post.Body, 3)\n+card\n"},
+	testcase{"-#   a comment\n-#\n    nested", "-# a comment\n-#\n  nested\n"},
+}
+
+func TestFormat(t *testing.T) {
+	for i, io := range formatTests {
+		output, err := Format([]byte(io.input))
+		if err != nil {
+			t.Errorf("(%d) Input %q\nunexpected error %s", i, io.input, err)
+			continue
+		}
+		if string(output) != io.expected {
+			t.Errorf("(%d) Input    %q\nexpected %q\ngot      %q", i, io.input, io.expected, output)
+		}
+	}
+}
+
+var commentTests = []testcase{
+	testcase{"-# hidden\n%p", "<p />"},
+	testcase{"%p\n  -# hidden\n  %a\n  -# also\n    hidden too\n  %b", "<p>\n\t<a />\n\t<b />\n</p>"},
+	testcase{"%p first\n-# hidden\n%p second", "<p>first</p>\n<p>second</p>"},
+}
+
+func TestComments(t *testing.T) {
+	for i, io := range commentTests {
+		engine, err := NewEngine(io.input)
+		if err != nil {
+			t.Errorf("(%d) Input %q\nunexpected error %s", i, io.input, err)
+			continue
+		}
+		output := engine.Render(make(map[string]interface{}))
+		if output != io.expected {
+			t.Errorf("(%d) Input    %q\nexpected %q\ngot      %q", i, io.input, io.expected, output)
+		}
+	}
+}
+
+func formatTestScope() map[string]interface{} {
+	scope := make(map[string]interface{})
+	complexLookup := complexLookup{"Fortune presents gifts not according to the book.",
+		simpleLookup{"That's what I said.", 5, .1,
+			&simpleLookup{"Down deep.", 3, .2, nil}},
+		map[string]interface{}{"key": "I got map!"}}
+	scope["complexKey"] = complexLookup
+	scope["key1"] = "value1"
+	scope["key2"] = "value2"
+	scope["lang"] = "HAML"
+	scope["outputFalse"] = "false"
+	scope["outputTrue"] = "true"
+	scope["cd"] = "checked"
+	scope["post"] = map[string]interface{}{
+		"Body":   "The body.",
+		"Author": struct{ Name string }{"Jane"},
+	}
+	return scope
+}
+
+// TestFormatRoundTrip checks that formatting leaves the rendered output of
+// the test templates unchanged and that formatted templates stay as they are.
+func TestFormatRoundTrip(t *testing.T) {
+	var inputs []string
+	for _, cases := range [][]testcase{autoCloseTests, nestingTests, mixinTests, commentTests} {
+		for _, io := range cases {
+			inputs = append(inputs, io.input)
+		}
+	}
+	fixtures, _ := filepath.Glob("test/*.haml")
+	for _, fixture := range fixtures {
+		b, err := ioutil.ReadFile(fixture)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inputs = append(inputs, string(b))
+	}
+
+	for i, input := range inputs {
+		formatted, err := Format([]byte(input))
+		if err != nil {
+			t.Errorf("(%d) Input %q\nunexpected error %s", i, input, err)
+			continue
+		}
+		original, _ := NewEngine(input)
+		engine, err := NewEngine(string(formatted))
+		if err != nil {
+			t.Errorf("(%d) Input %q\nformatted %q does not parse: %s", i, input, formatted, err)
+			continue
+		}
+		expected, output := original.Render(formatTestScope()), engine.Render(formatTestScope())
+		if output != expected {
+			t.Errorf("(%d) Input    %q\nformatted %q\nexpected %q\ngot      %q", i, input, formatted, expected, output)
+		}
+		again, err := Format(formatted)
+		if err != nil || string(again) != string(formatted) {
+			t.Errorf("(%d) Input %q\nformatting %q again gave %q (%v)", i, input, formatted, again, err)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package gohaml
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type money int
+
+func (self money) String() string {
+	return "$" + string(rune('0'+int(self)))
+}
+
+type shout string
+
+func (self *shout) String() string {
+	return strings.ToUpper(string(*self))
+}
+
+type level int
+
+func (self level) MarshalText() ([]byte, error) {
+	return []byte([]string{"low", "high"}[self]), nil
+}
+
+func formatterTestScope() map[string]interface{} {
+	s := shout("hey")
+	var nilShout *shout
+	return map[string]interface{}{
+		"when":    time.Date(2024, 3, 5, 14, 7, 0, 0, time.UTC),
+		"yes":     true,
+		"big":     uint64(18446744073709551615),
+		"small":   uint8(7),
+		"price":   money(5),
+		"prices":  []money{1, 2},
+		"loud":    &s,
+		"nilLoud": nilShout,
+		"level":   level(1),
+		"ip":      net.IPv4(127, 0, 0, 1),
+		"err":     errors.New("failed"),
+		"any":     interface{}(money(3)),
+	}
+}
+
+var formatterTests = []testcase{
+	testcase{"= when", "2024-03-05T14:07:00Z"},
+	testcase{"%p{:title => when}", "<p title=\"2024-03-05T14:07:00Z\" />"},
+	testcase{"= yes", "true"},
+	testcase{"= big<\n= small", "184467440737095516157"},
+	testcase{"%b= price", "<b>$5</b>"},
+	testcase{"%b{:class => price}", "<b class=\"$5\" />"},
+	testcase{"- for i, p := range prices\n  = p", "$1\n$2"},
+	testcase{"= loud", "HEY"},
+	testcase{"%p= nilLoud", "<p />"},
+	testcase{"= level", "high"},
+	testcase{"= ip", "127.0.0.1"},
+	testcase{"= err", "failed"},
+	testcase{"= any", "$3"},
+	testcase{"- x := price\n= x", "$5"},
+}
+
+func TestFormatValues(t *testing.T) {
+	for i, io := range formatterTests {
+		engine, _ := NewEngine(io.input)
+		output := engine.Render(formatterTestScope())
+		if output != io.expected {
+			t.Errorf("(%d) Input    %q\nexpected %q\ngot      %q", i, io.input, io.expected, output)
+		}
+	}
+}
+
+var customFormatterTests = []testcase{
+	testcase{"= when", "5 Mar 2024"},
+	testcase{"%time{:datetime => when}", "<time datetime=\"5 Mar 2024\" />"},
+	testcase{"= yes", "yes"},
+	testcase{"= price", "5.00"},
+	testcase{"= any", "3.00"},
+	testcase{"= level", "high"},
+}
+
+func TestSetFormatter(t *testing.T) {
+	for i, io := range customFormatterTests {
+		engine, _ := NewEngine(io.input)
+		engine.SetFormatter(reflect.TypeOf(time.Time{}), func(v interface{}) string {
+			return v.(time.Time).Format("2 Jan 2006")
+		})
+		engine.SetFormatter(reflect.TypeOf(true), func(v interface{}) string {
+			if v.(bool) {
+				return "yes"
+			}
+			return "no"
+		})
+		engine.SetFormatter(reflect.TypeOf(money(0)), func(v interface{}) string {
+			return string(rune('0'+int(v.(money)))) + ".00"
+		})
+		output := engine.Render(formatterTestScope())
+		if output != io.expected {
+			t.Errorf("(%d) Input    %q\nexpected %q\ngot      %q", i, io.input, io.expected, output)
+		}
+	}
+
+	engine, _ := NewEngine("= yes")
+	engine.SetFormatter(reflect.TypeOf(true), func(v interface{}) string { return "yes" })
+	engine.SetFormatter(reflect.TypeOf(true), nil)
+	if output := engine.Render(formatterTestScope()); output != "true" {
+		t.Errorf("expected the removed Formatter not to be used, got %q", output)
+	}
+}
@@ -32,6 +32,70 @@ func TestForSliceRangeConstruct(t *testing.T) {
 	}
 }
 
+func TestForPathRangeConstruct(t *testing.T) {
+	scope := make(map[string]interface{})
+	scope["post"] = map[string]interface{}{"Tags": []string{"go", "haml"}}
+
+	expected := "<p>\n" +
+		"	<span>0</span><span>go</span>\n" +
+		"	<span>1</span><span>haml</span>\n" +
+		"</p>"
+	input := "%p\n  - for i, v := range post.Tags\n    %span= i<\n    %span= v"
+	engine, _ := NewEngine(input)
+	output := engine.Render(scope)
+
+	if output != expected {
+		t.Errorf("Expected\n%s\nbut got\n%s\n", expected, output)
+	}
+}
+
+type pathAuthor struct {
+	Name string
+}
+
+func (self pathAuthor) Initials() string {
+	return self.Name[:1]
+}
+
+func (self *pathAuthor) Greet(greeting string) string {
+	return greeting + " " + self.Name
+}
+
+func (self pathAuthor) Tags() []string {
+	return []string{"go", "haml"}
+}
+
+type pathMeta map[string]string
+
+// Kind hides the key of the same name.
+func (self pathMeta) Kind() string {
+	return "method"
+}
+
+func TestPathMethods(t *testing.T) {
+	scope := map[string]interface{}{
+		"author": pathAuthor{"Jane"},
+		"ptr":    &pathAuthor{"Kim"},
+		"nilptr": (*pathAuthor)(nil),
+		"meta":   pathMeta{"Kind": "key", "Lang": "en"},
+	}
+	for i, io := range []testcase{
+		testcase{"%p= author.Initials", "<p>J</p>"},
+		testcase{"%p= ptr.Initials", "<p>K</p>"},
+		testcase{"%p= meta.Kind", "<p>method</p>"},
+		testcase{"%p= meta.Lang", "<p>en</p>"},
+		testcase{"%p= ptr.Name", "<p>Kim</p>"},
+		testcase{"%p= ptr.Greet", "<p />"},
+		testcase{"%p= nilptr.Initials", "<p />"},
+		testcase{"- for i, tag := range author.Tags\n  = tag", "go\nhaml"},
+	} {
+		engine, _ := NewEngine(io.input)
+		if output := engine.Render(scope); output != io.expected {
+			t.Errorf("(%d) Input    %q\nexpected %q\ngot      %q", i, io.input, io.expected, output)
+		}
+	}
+}
+
 func TestForArrayRangeConstruct(t *testing.T) {
 	scope := make(map[string]interface{})
 	scope["looper"] = [5]int{4, -128, 38, 99, 1}
@@ -59,7 +59,7 @@ var autoCloseTests = []testcase{
 	testcase{"%p= key1", "<p>value1</p>"},
 	testcase{"%tag{:attribute1 => \"value1\", :attribute2 => \"value2\"}", "<tag attribute1=\"value1\" attribute2=\"value2\" />"},
 	testcase{"%tag{:attribute1 => \"value1\", :attribute2 => \"value2\"} tag content", "<tag attribute1=\"value1\" attribute2=\"value2\">tag content</tag>"},
-	testcase{"%tag#tagId.tagClass{:id => \"tagId\", :class => \"tagClass\"} tag content", "<tag id=\"tagId tagId\" class=\"tagClass tagClass\">tag content</tag>"},
+	testcase{"%tag#tagId.tagClass{:id => \"tagId\", :class => \"tagClass\"} tag content", "<tag id=\"tagId_tagId\" class=\"tagClass\">tag content</tag>"},
 	testcase{"%tag#tagId{:attribute => \"value\"} tag content", "<tag id=\"tagId\" attribute=\"value\">tag content</tag>"},
 	testcase{"%input{:type => \"checkbox\", :checked => true}", "<input type=\"checkbox\" checked=\"checked\" />"},
 	testcase{"%input{:type => \"checkbox\", :checked => false}", "<input type=\"checkbox\" />"},
@@ -0,0 +1,59 @@
+package gohaml
+
+import (
+	"strings"
+	"testing"
+)
+
+var lintTests = []testcase{
+	testcase{"%p= key1", ""},
+	testcase{"- x := 1\n%p= key1", "1:1: warning: x is assigned but never used (unused-assignment)"},
+	testcase{"- x := key1.Name\n%p= x.First", ""},
+	testcase{"-# - x := 1\n  - y := 2", ""},
+	testcase{"- for i, v := range list\n  - for j, v := range v\n    %p= j", "2:3: warning: range variable v shadows the range variable on line 1 (shadowed-range-var)"},
+	testcase{"- def item(v)\n  - for i, v := range v\n    = i", "2:3: warning: range variable v shadows the parameter of mixin item (shadowed-range-var)"},
+	testcase{"%include simple.haml\n%include missing.haml", "2:1: error: included template \"missing.haml\" does not exist (include-missing)"},
+	testcase{"#main\n%p\n  %span#main{:id => key1}\n  %a{:id => \"main\"}", "4:3: warning: duplicate id \"main\", first used on line 1 (duplicate-id)"},
+	testcase{"- for i, v := range list\n  %li#item= v", "2:3: warning: id \"item\" is repeated on every iteration of the loop on line 1 (duplicate-id)"},
+	testcase{"%p<\n  %a\n%b<\n%i\n  %b<\n  %c\n%q<", "5:3: warning: < has no effect here (ineffective-nonewline)\n7:1: warning: < has no effect here (ineffective-nonewline)"},
+	testcase{"%p\n\t%a\n  %b", "3:1: warning: indentation mixes tabs and spaces (mixed-indentation)"},
+	testcase{"%p\n  #\n", "2:1: error: Syntax error on line 2: Illegal element: classes and ids must have values. (syntax)"},
+}
+
+func TestLint(t *testing.T) {
+	loader, err := NewFileSystemLoader("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, io := range lintTests {
+		var issues []string
+		for _, issue := range Lint(io.input, loader) {
+			issues = append(issues, issue.String())
+		}
+		output := strings.Join(issues, "\n")
+		if output != io.expected {
+			t.Errorf("(%d) Input    %q\nexpected %q\ngot      %q", i, io.input, io.expected, output)
+		}
+	}
+}
+
+func TestEngineLint(t *testing.T) {
+	loader, err := NewFileSystemLoader("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, io := range lintTests {
+		engine, err := NewEngine(io.input)
+		if err != nil {
+			continue
+		}
+		var issues []string
+		for _, issue := range engine.Lint(loader) {
+			issues = append(issues, issue.String())
+		}
+		output := strings.Join(issues, "\n")
+		if output != io.expected {
+			t.Errorf("(%d) Input    %q\nexpected %q\ngot      %q", i, io.input, io.expected, output)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package gohaml
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+type lookupTestBase struct {
+	ID int
+}
+
+type lookupTestUser struct {
+	lookupTestBase
+	Name   string
+	Email  string `json:"email_address,omitempty"`
+	Nick   string `haml:"nickname" json:"nick"`
+	Secret string `haml:"-" json:"secret"`
+	Token  string `json:"-"`
+	Base   *lookupTestBase
+}
+
+func (self lookupTestUser) Upper() string {
+	return "TOM"
+}
+
+func lookupTestScope() map[string]interface{} {
+	return map[string]interface{}{"user": &lookupTestUser{
+		lookupTestBase: lookupTestBase{7},
+		Name:           "Tom",
+		Email:          "tom@example.com",
+		Nick:           "tommy",
+		Secret:         "hunter2",
+		Token:          "t0k3n",
+	}}
+}
+
+var lookupTests = []struct {
+	lookup FieldLookup
+	tests  []testcase
+}{
+	{0, []testcase{
+		testcase{"%p{:title => user.Name}", "<p title=\"Tom\" />"},
+		testcase{"%p{:title => user.name}", "<p />"},
+		testcase{"%p{:title => user.Secret}", "<p />"},
+		testcase{"%p{:title => user.Token}", "<p />"},
+		testcase{"%p{:title => user.ID}", "<p title=\"7\" />"},
+		testcase{"%p{:title => user.email_address}", "<p />"},
+	}},
+	{LookupTags, []testcase{
+		testcase{"%p{:title => user.Name}", "<p title=\"Tom\" />"},
+		testcase{"%p{:title => user.email_address}", "<p title=\"tom@example.com\" />"},
+		testcase{"%p{:title => user.Email}", "<p />"},
+		testcase{"%p{:title => user.nickname}", "<p title=\"tommy\" />"},
+		testcase{"%p{:title => user.nick}", "<p />"},
+		testcase{"%p{:title => user.Secret}", "<p />"},
+		testcase{"%p{:title => user.secret}", "<p />"},
+		testcase{"%p{:title => user.Token}", "<p />"},
+		testcase{"%p{:title => user.ID}", "<p title=\"7\" />"},
+		testcase{"%p{:title => user.Base.ID}", "<p />"},
+		testcase{"%p{:title => user.name}", "<p />"},
+	}},
+	{LookupFoldCase, []testcase{
+		testcase{"%p{:title => user.name}", "<p title=\"Tom\" />"},
+		testcase{"%p{:title => user.Name}", "<p title=\"Tom\" />"},
+		testcase{"%p{:title => user.email}", "<p title=\"tom@example.com\" />"},
+		testcase{"%p{:title => user.upper}", "<p title=\"TOM\" />"},
+		testcase{"%p{:title => user.nAME}", "<p />"},
+		testcase{"%p{:title => user.secret}", "<p />"},
+		testcase{"%p{:title => user.Secret}", "<p />"},
+		testcase{"%p{:title => user.token}", "<p />"},
+		testcase{"%p{:title => user.Token}", "<p />"},
+	}},
+	{LookupTags | LookupFoldCase, []testcase{
+		testcase{"%p{:title => user.name}", "<p title=\"Tom\" />"},
+		testcase{"%p{:title => user.Nickname}", "<p title=\"tommy\" />"},
+		testcase{"%p{:title => user.Email_address}", "<p title=\"tom@example.com\" />"},
+		testcase{"%p{:title => user.secret}", "<p />"},
+	}},
+}
+
+func TestFieldLookup(t *testing.T) {
+	for _, policy := range lookupTests {
+		for i, io := range policy.tests {
+			engine, _ := NewEngine(io.input)
+			engine.FieldLookup = policy.lookup
+			output := engine.Render(lookupTestScope())
+			if output != io.expected {
+				t.Errorf("(%d, %d) Input    %q\nexpected %q\ngot      %q", policy.lookup, i, io.input, io.expected, output)
+			}
+		}
+	}
+}
+
+func TestFieldLookupRenderValue(t *testing.T) {
+	engine, _ := NewEngine("%p= name")
+	engine.FieldLookup = LookupFoldCase
+	if output, err := engine.RenderValue(lookupTestScope()["user"]); err != nil || output != "<p>Tom</p>" {
+		t.Errorf("expected %q, got %q %v", "<p>Tom</p>", output, err)
+	}
+}
+
+func TestFieldLookupCheckAgainst(t *testing.T) {
+	engine, _ := NewEngine("= name\n= nickname\n= secret\n= upper")
+	engine.FieldLookup = LookupTags | LookupFoldCase
+	errs := engine.CheckAgainst(reflect.TypeOf(lookupTestUser{}))
+	if len(errs) != 1 || errs[0].Error() != "line 3: secret: no field or method secret in gohaml.lookupTestUser" {
+		t.Errorf("unexpected errors %v", errs)
+	}
+
+	engine.FieldLookup = 0
+	if err := engine.Generate(ioutil.Discard, GenerateOptions{"views", "Render", "*Page"}); err != nil {
+		t.Fatal(err)
+	}
+	engine.FieldLookup = LookupTags
+	if err := engine.Generate(ioutil.Discard, GenerateOptions{"views", "Render", "*Page"}); err == nil {
+		t.Errorf("expected an error for FieldLookup")
+	}
+}
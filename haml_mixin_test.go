@@ -0,0 +1,48 @@
+package gohaml
+
+import "testing"
+
+var mixinTests = []testcase{
+	testcase{"- def card(title, body)\n  .card\n    %h2= title\n    %p= body\n+card(\"Hi\", post.Body)", "<div class=\"card\">\n\t<h2>Hi</h2>\n\t<p>The body.</p>\n</div>"},
+	testcase{"- def card(title, body)\n  .card\n    %h2= title\n    %p= body\n+card(\"Hi\", post.Body)\n+card(\"Bye\", 3)", "<div class=\"card\">\n\t<h2>Hi</h2>\n\t<p>The body.</p>\n</div>\n<div class=\"card\">\n\t<h2>Bye</h2>\n\t<p>3</p>\n</div>"},
+	testcase{"- def author(p)\n  %span= p.Author.Name\n+author(post)", "<span>Jane</span>"},
+	testcase{"- def hr\n  %hr\n%p\n  +hr\n  +hr()", "<p>\n\t<hr />\n\t<hr />\n</p>"},
+	testcase{"- def box(title)\n  %div\n    %h3= title\n    - yield\n+box(\"Hi\")\n  %span= key1", "<div>\n\t<h3>Hi</h3>\n\t<span>value1</span>\n</div>"},
+	testcase{"- def outer\n  %o\n    - yield\n- def inner\n  +outer\n    %i\n      - yield\n+inner\n  %b", "<o>\n\t<i>\n\t\t<b />\n\t</i>\n</o>"},
+	testcase{"- def loop(x)\n  +loop(x)\n+loop(1)", ""},
+	testcase{"+undefined(1)", ""},
+	testcase{"%p\n  +1 for this", "<p>\n\t+1 for this\n</p>"},
+	testcase{"+ more", "+ more"},
+	testcase{"- def scoped(key1)\n  - key2 := \"changed\"\n  = key1\n+scoped(\"arg\")\n= key2", "arg\nvalue2"},
+}
+
+func TestMixins(t *testing.T) {
+	for i, io := range mixinTests {
+		scope := make(map[string]interface{})
+		scope["key1"] = "value1"
+		scope["key2"] = "value2"
+		scope["post"] = map[string]interface{}{
+			"Body":   "The body.",
+			"Author": struct{ Name string }{"Jane"},
+		}
+
+		engine, err := NewEngine(io.input)
+		if err != nil {
+			t.Errorf("(%d) Input %q\nunexpected error %s", i, io.input, err)
+			continue
+		}
+		output := engine.Render(scope)
+		if output != io.expected {
+			t.Errorf("(%d) Input    %q\nexpected %q\ngot      %q", i, io.input, io.expected, output)
+		}
+	}
+}
+
+func TestMixinErrors(t *testing.T) {
+	for i, input := range []string{"+undefined(1)", "%p\n  +undefined", "- def loop(x)\n  +loop(x)\n+loop(1)"} {
+		engine, _ := NewEngine(input)
+		if _, err := engine.RenderValue(nil); err == nil {
+			t.Errorf("(%d) Input %q\nexpected an error", i, input)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package gohaml
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/realistschuckle/gohaml/ast"
+)
+
+// describe writes a node and its fields on one line, for comparison.
+func describe(n ast.Node) string {
+	pos := fmt.Sprintf("%d:%d", n.Position().Line, n.Position().Column)
+	switch n := n.(type) {
+	case *ast.Doctype:
+		return fmt.Sprintf("%s doctype %q", pos, n.Value)
+	case *ast.Tag:
+		var attrs []string
+		for _, attr := range n.Attrs {
+			attrs = append(attrs, fmt.Sprintf("%v=%v", attr.Key, attr.Value))
+		}
+		return fmt.Sprintf("%s tag %s [%s] %v <%v /%v", pos, n.Name, strings.Join(attrs, " "), n.Content, n.NoNewline, n.SelfClose)
+	case *ast.Text:
+		return fmt.Sprintf("%s text %v <%v", pos, n.Content, n.NoNewline)
+	case *ast.Comment:
+		return fmt.Sprintf("%s comment %q", pos, n.Text)
+	case *ast.Assign:
+		return fmt.Sprintf("%s assign %s %v", pos, n.Name, n.Value)
+	case *ast.Range:
+		return fmt.Sprintf("%s range %s %s %v", pos, n.Key, n.Value, n.Target)
+	case *ast.Def:
+		return fmt.Sprintf("%s def %s %v", pos, n.Name, n.Params)
+	case *ast.Call:
+		return fmt.Sprintf("%s call %s %v", pos, n.Name, n.Args)
+	case *ast.Yield:
+		return pos + " yield"
+	}
+	return pos + " ?"
+}
+
+var parseTests = []testcase{
+	testcase{"!!! 5\n%html", "1:1 doctype \"5\"\n2:1 tag html [] { false} <false /false"},
+	testcase{"#main.wide{:title => key1, key2 => \"x\"}= key3", "1:1 tag div [{id false}={main false} {class false}={wide false} {title false}={key1 true} {key2 true}={x false}] {key3 true} <false /false"},
+	testcase{"%p\n  %br/\n  plain<\n  = key1", "1:1 tag p [] { false} <false /false\n2:3 tag br [] { false} <false /true\n3:3 text {plain false} <true\n4:3 text {key1 true} <false"},
+	testcase{"- x := 1.5\n- y := post.Title\n-# note\n  hidden", "1:1 assign x {1.5 }\n2:1 assign y {<nil> post.Title}\n3:1 comment \"note\"\n4:3 text {hidden false} <false"},
+	testcase{"- def card(title)\n  - yield\n- for i, v := range list\n  +card(\"a\", v.Name)", "1:1 def card [title]\n2:3 yield\n3:1 range i v {<nil> list}\n4:3 call card [{a } {<nil> v.Name}]"},
+	testcase{"%a{:href => url, extra}", "1:1 tag a [{href false}={url true} { false}={extra true}] { false} <false /false"},
+	testcase{"- for v := range list\n- for _, v := range list\n- for range 3", "1:1 range v  {<nil> list}\n2:1 range _ v {<nil> list}\n3:1 range   {3 }"},
+}
+
+func TestParse(t *testing.T) {
+	for i, io := range parseTests {
+		file, err := Parse(io.input)
+		if err != nil {
+			t.Errorf("(%d) Input %q\nunexpected error %s", i, io.input, err)
+			continue
+		}
+		var nodes []string
+		ast.Inspect(file, func(n ast.Node) bool {
+			if n != nil {
+				nodes = append(nodes, describe(n))
+			}
+			return true
+		})
+		output := strings.Join(nodes, "\n")
+		if output != io.expected {
+			t.Errorf("(%d) Input    %q\nexpected %q\ngot      %q", i, io.input, io.expected, output)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	if _, err := Parse("%p\n  #"); err == nil || err.(*SyntaxError).Line != 2 {
+		t.Errorf("expected a syntax error on line 2, got %v", err)
+	}
+}
+
+// TestParseConcurrent parses templates from many goroutines; run with -race,
+// it checks that the parser keeps no state between parses.
+func TestParseConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			input := fmt.Sprintf("- n := %d\n- for _, v := range items\n  = v\n%%p= n", i)
+			for j := 0; j < 20; j++ {
+				engine, err := NewEngine(input)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				expected := fmt.Sprintf("a\nb\n<p>%d</p>", i)
+				if output := engine.Render(map[string]interface{}{"items": []string{"a", "b"}}); output != expected {
+					t.Errorf("(%d) expected %q\ngot      %q", i, expected, output)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
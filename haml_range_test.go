@@ -0,0 +1,146 @@
+package gohaml
+
+import "testing"
+
+type rangeUser struct {
+	Name string
+}
+
+func rangeTestScope() map[string]interface{} {
+	ch := make(chan string, 3)
+	ch <- "a"
+	ch <- "b"
+	close(ch)
+	var recv <-chan string = ch
+	return map[string]interface{}{
+		"ch":   ch,
+		"recv": recv,
+		"send": make(chan<- string),
+		"n":    3,
+		"word": "héllo",
+		"seq": func(yield func(string) bool) {
+			for _, s := range []string{"x", "y", "z"} {
+				if !yield(s) {
+					return
+				}
+			}
+		},
+		"seq2": func(yield func(string, rangeUser) bool) {
+			_ = yield("first", rangeUser{"Ann"}) && yield("second", rangeUser{"Bob"})
+		},
+		"notSeq": func(s string) {},
+		"ptr":    &[]string{"p", "q"},
+		"users":  []*rangeUser{{"Ann"}, nil, {"Bob"}},
+		"byName": map[string]rangeUser{"zed": {"Zed"}, "amy": {"Amy"}, "kim": {"Kim"}},
+		"byID":   map[int]string{10: "ten", 2: "two", -1: "minus one"},
+		"mixed":  map[interface{}]int{"b": 1, 2: 2, "a": 3, 1: 4},
+		"matrix": [][]int{{1, 2}, {3}},
+		"groups": map[string][]rangeUser{"b": {{"Bea"}}, "a": {{"Al"}, {"Ada"}}},
+		"any":    []interface{}{rangeUser{"Ian"}, []string{"x"}, true, 1.5},
+	}
+}
+
+var rangeTests = []testcase{
+	testcase{"- for i, v := range ch\n  %p= i<\n  %p= v", "<p>0</p><p>a</p>\n<p>1</p><p>b</p>"},
+	testcase{"- for i, v := range recv\n  = v", "a\nb"},
+	testcase{"- for i, v := range send\n  = v\n%p", "\n<p />"},
+	testcase{"- for i, v := range 3\n  = i\n  = v", "0\n0\n1\n1\n2\n2"},
+	testcase{"- for i, v := range 0\n  = i", ""},
+	testcase{"- for i, v := range n\n  = i", "0\n1\n2"},
+	testcase{"- for i, c := range word\n  %b= i<\n  = c", "<b>0</b>h\n<b>1</b>é\n<b>3</b>l\n<b>4</b>l\n<b>5</b>o"},
+	testcase{"- for i, c := range \"ab\"\n  = c", "a\nb"},
+	testcase{"- for i, v := range seq\n  %li= i<\n  = v", "<li>0</li>x\n<li>1</li>y\n<li>2</li>z"},
+	testcase{"- for k, u := range seq2\n  %dt= k\n  %dd= u.Name", "<dt>first</dt>\n<dd>Ann</dd>\n<dt>second</dt>\n<dd>Bob</dd>"},
+	testcase{"- for i, v := range notSeq\n  = v", ""},
+	testcase{"- for i, v := range ptr\n  = v", "p\nq"},
+	testcase{"%ul\n  - for i, v := range 2\n    %li= v\n    %li<\n  %p", "<ul>\n\t<li>0</li>\n\t<li /><li>1</li>\n\t<li />\n\t<p />\n</ul>"},
+	testcase{"- for i, u := range users\n  %p= u.Name", "<p>Ann</p>\n<p />\n<p>Bob</p>"},
+	testcase{"- for k, u := range byName\n  %p{:id => k}= u.Name", "<p id=\"amy\">Amy</p>\n<p id=\"kim\">Kim</p>\n<p id=\"zed\">Zed</p>"},
+	testcase{"- for k, v := range byID\n  = k<\n  = v", "-1minus one\n2two\n10ten"},
+	testcase{"- for k, v := range mixed\n  = k", "1\n2\na\nb"},
+	testcase{"- for i, row := range matrix\n  %tr\n    - for j, cell := range row\n      %td= cell", "<tr>\n\t<td>1</td>\n\t<td>2</td>\n</tr>\n<tr>\n\t<td>3</td>\n</tr>"},
+	testcase{"- for g, members := range groups\n  %h2= g\n  - for i, m := range members\n    %p= m.Name", "<h2>a</h2>\n<p>Al</p>\n<p>Ada</p>\n<h2>b</h2>\n<p>Bea</p>"},
+	testcase{"- for i, v := range any\n  = v", "{Ian}\n[x]\ntrue\n1.5"},
+	testcase{"- for i := range ptr\n  = i", "0\n1"},
+	testcase{"- for k := range byID\n  = k", "-1\n2\n10"},
+	testcase{"- for i := range word\n  = i", "0\n1\n3\n4\n5"},
+	testcase{"- for v := range ch\n  = v", "a\nb"},
+	testcase{"- for v := range seq\n  = v", "x\ny\nz"},
+	testcase{"- for k := range seq2\n  = k", "first\nsecond"},
+	testcase{"- for _, u := range byName\n  = u.Name", "Amy\nKim\nZed"},
+	testcase{"- for k, _ := range byID\n  = k", "-1\n2\n10"},
+	testcase{"- for range 3\n  %hr", "<hr />\n<hr />\n<hr />"},
+	testcase{"- for range seq\n  %hr", "<hr />\n<hr />\n<hr />"},
+}
+
+var loopTests = []testcase{
+	testcase{"- for _, v := range ptr\n  %p= loop.Index<\n  = loop.Number<\n  = loop.Length", "<p>0</p>12\n<p>1</p>22"},
+	testcase{"- for range 3\n  %p= loop.First<\n  = loop.Last", "<p>true</p>false\n<p>false</p>false\n<p>false</p>true"},
+	testcase{"- for v := range ch\n  %p= v<\n  = loop.Length<\n  = loop.Last", "<p>a</p>-1false\n<p>b</p>-1true"},
+	testcase{"%tr\n  - for _, v := range seq\n    %td{:class => loop.Parity}= v", "<tr>\n\t<td class=\"even\">x</td>\n\t<td class=\"odd\">y</td>\n\t<td class=\"even\">z</td>\n</tr>"},
+	testcase{"%p\n  - for _, v := range seq\n    = v<\n    = loop.Comma<", "<p>\n\tx, y, z\n</p>"},
+	testcase{"- for i, row := range matrix\n  - for j, cell := range row\n    = loop.Parent.Number<\n    = loop.Number", "11\n12\n21"},
+}
+
+func TestRangeSources(t *testing.T) {
+	for i, io := range rangeTests {
+		engine, err := NewEngine(io.input)
+		if err != nil {
+			t.Errorf("(%d) Input %q\nunexpected error %s", i, io.input, err)
+			continue
+		}
+		output := engine.Render(rangeTestScope())
+		if output != io.expected {
+			t.Errorf("(%d) Input    %q\nexpected %q\ngot      %q", i, io.input, io.expected, output)
+		}
+	}
+}
+
+func TestLoopMetadata(t *testing.T) {
+	for i, io := range loopTests {
+		engine, err := NewEngine(io.input)
+		if err != nil {
+			t.Errorf("(%d) Input %q\nunexpected error %s", i, io.input, err)
+			continue
+		}
+		output := engine.Render(rangeTestScope())
+		if output != io.expected {
+			t.Errorf("(%d) Input    %q\nexpected %q\ngot      %q", i, io.input, io.expected, output)
+		}
+	}
+}
+
+func TestLoopRestoresScope(t *testing.T) {
+	engine, _ := NewEngine("- for range 1\n  = loop.Index\n= loop")
+	scope := map[string]interface{}{"loop": "outer"}
+	expected := "0\nouter"
+	if output := engine.Render(scope); output != expected {
+		t.Errorf("expected %q\ngot      %q", expected, output)
+	}
+
+	scope = map[string]interface{}{}
+	engine.Render(scope)
+	if _, ok := scope["loop"]; ok {
+		t.Errorf("loop left in scope after the range: %v", scope)
+	}
+}
+
+func TestRangeLazy(t *testing.T) {
+	// each item may only be produced once the one two before it is written.
+	n := 0
+	seq := func(yield func(renderCounter) bool) {
+		for i := 0; i < 4; i++ {
+			if n < i-1 {
+				t.Errorf("item %d produced with %d items written", i, n)
+			}
+			if !yield(renderCounter{&n}) {
+				return
+			}
+		}
+	}
+	engine, _ := NewEngine("- for v := range seq\n  = v")
+	expected := "1\n2\n3\n4"
+	if output := engine.Render(map[string]interface{}{"seq": seq}); output != expected {
+		t.Errorf("expected %q\ngot      %q", expected, output)
+	}
+}
@@ -0,0 +1,205 @@
+package gohaml
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/realistschuckle/gohaml/ast"
+)
+
+var fingerprints = map[string]string{"/app.css": "/app.3f2a.css"}
+
+func nonces(file *ast.File) error {
+	ast.Inspect(file, func(n ast.Node) bool {
+		if tag, ok := n.(*ast.Tag); ok && tag.Name == "script" {
+			tag.SetAttr("nonce", ast.Value{Text: "nonce", IsPath: true})
+		}
+		return true
+	})
+	return nil
+}
+
+func assets(file *ast.File) error {
+	ast.Inspect(file, func(n ast.Node) bool {
+		if tag, ok := n.(*ast.Tag); ok && tag.Name == "link" {
+			if href, ok := tag.Attr("href"); ok && !href.IsPath && fingerprints[href.Text] != "" {
+				tag.SetAttr("href", ast.Value{Text: fingerprints[href.Text]})
+			}
+		}
+		return true
+	})
+	return nil
+}
+
+func noopener(file *ast.File) error {
+	ast.Inspect(file, func(n ast.Node) bool {
+		if tag, ok := n.(*ast.Tag); ok && tag.Name == "a" {
+			if href, ok := tag.Attr("href"); ok && !href.IsPath && strings.HasPrefix(href.Text, "http") {
+				tag.SetAttr("rel", ast.Value{Text: "noopener"})
+			}
+		}
+		return true
+	})
+	return nil
+}
+
+func footer(file *ast.File) error {
+	file.Nodes = append(file.Nodes, &ast.Tag{Name: "footer", Children: []ast.Node{
+		&ast.Text{Content: ast.Value{Text: "key1", IsPath: true}},
+	}})
+	return nil
+}
+
+var transformTests = []testcase{
+	testcase{"%script{:src => \"/app.js\"}", "<script src=\"/app.js\" nonce=\"r4nd0m\" />"},
+	testcase{"%script{:nonce => \"old\", :nonce => \"older\"}", "<script nonce=\"r4nd0m\" />"},
+	testcase{"%link{:rel => \"stylesheet\", :href => \"/app.css\"}\n%link{:href => \"/other.css\"}", "<link rel=\"stylesheet\" href=\"/app.3f2a.css\" />\n<link href=\"/other.css\" />"},
+	testcase{"%a{:href => \"https://example.com\"} out\n%a{:href => \"/home\"} in", "<a href=\"https://example.com\" rel=\"noopener\">out</a>\n<a href=\"/home\">in</a>"},
+	testcase{"- def page\n  %main\n    %script\n+page", "<main>\n\t<script nonce=\"r4nd0m\" />\n</main>\n<footer>\n\tvalue1\n</footer>"},
+}
+
+func TestTransform(t *testing.T) {
+	for i, io := range transformTests {
+		engine, err := NewEngine(io.input)
+		if err != nil {
+			t.Errorf("(%d) Input %q\nunexpected error %s", i, io.input, err)
+			continue
+		}
+		transformers := []Transformer{nonces, assets, noopener}
+		if strings.Contains(io.input, "def") {
+			transformers = append(transformers, footer)
+		}
+		if err = engine.Transform(transformers...); err != nil {
+			t.Errorf("(%d) Input %q\nunexpected error %s", i, io.input, err)
+			continue
+		}
+		output := engine.Render(map[string]interface{}{"nonce": "r4nd0m", "key1": "value1"})
+		if output != io.expected {
+			t.Errorf("(%d) Input    %q\nexpected %q\ngot      %q", i, io.input, io.expected, output)
+		}
+	}
+}
+
+func TestTransformError(t *testing.T) {
+	engine, _ := NewEngine("%p hi")
+	failure := errors.New("failed")
+	err := engine.Transform(footer, func(*ast.File) error { return failure })
+	if err != failure {
+		t.Errorf("expected the error of the transformer, got %v", err)
+	}
+	if output := engine.Render(nil); output != "<p>hi</p>" {
+		t.Errorf("unexpected output %q", output)
+	}
+	// the changes made before the failure are not kept either.
+	if err = engine.Transform(); err != nil {
+		t.Fatal(err)
+	}
+	if output := engine.Render(nil); output != "<p>hi</p>" {
+		t.Errorf("unexpected output %q", output)
+	}
+}
+
+func TestTransformNilNodes(t *testing.T) {
+	transformers := []Transformer{
+		func(file *ast.File) error {
+			file.Nodes = append(file.Nodes, nil)
+			return nil
+		},
+		func(file *ast.File) error {
+			file.Nodes[0].(*ast.Tag).Children = []ast.Node{(*ast.Text)(nil)}
+			return nil
+		},
+		func(file *ast.File) error {
+			file.Nodes[0].(*ast.Tag).Attrs = []*ast.Attr{nil}
+			return nil
+		},
+	}
+	for i, transform := range transformers {
+		engine, _ := NewEngine("%p hi")
+		if err := engine.Transform(transform); err == nil {
+			t.Errorf("(%d) expected an error", i)
+		}
+		if output := engine.Render(nil); output != "<p>hi</p>" {
+			t.Errorf("(%d) unexpected output %q", i, output)
+		}
+	}
+}
+
+func TestTransformMixins(t *testing.T) {
+	engine, _ := NewEngine("- def kept\n  %p kept\n- def dropped\n  %p dropped\n+kept\n+dropped")
+	err := engine.Transform(func(file *ast.File) error {
+		var nodes []ast.Node
+		for _, n := range file.Nodes {
+			if def, ok := n.(*ast.Def); !ok || def.Name != "dropped" {
+				nodes = append(nodes, n)
+			}
+		}
+		file.Nodes = nodes
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output, err := engine.RenderValue(nil); output != "<p>kept</p>\n" || err == nil {
+		t.Errorf("expected the removed mixin not to be callable, got %q %v", output, err)
+	}
+}
+
+func TestLoaderTransform(t *testing.T) {
+	dir := writeTemplates(t, map[string]string{
+		"_mixins.haml": "- def analytics\n  %script{:src => \"/a.js\"}",
+		"page.haml":    "%script\n+analytics",
+	})
+	loader, _ := NewFileSystemLoader(dir, nonces)
+	if _, err := loader.Load("_mixins.haml"); err != nil {
+		t.Fatal(err)
+	}
+	engine, err := loader.Load("page.haml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "<script nonce=\"n1\" />\n<script src=\"/a.js\" nonce=\"n1\" />"
+	if output := engine.Render(map[string]interface{}{"nonce": "n1"}); output != expected {
+		t.Errorf("expected %q\ngot      %q", expected, output)
+	}
+
+	failing, _ := NewFileSystemLoader(dir, func(*ast.File) error { return errors.New("failed") })
+	if _, err := failing.Load("page.haml"); err == nil {
+		t.Errorf("expected the error of the transformer")
+	}
+}
+
+func TestHandlerTransform(t *testing.T) {
+	dir := writeTemplates(t, map[string]string{"page.haml": "%script"})
+	n := 0
+	h, err := NewHamlHandlerWithOptions(dir, HandlerOptions{
+		Transformers: []Transformer{nonces},
+		Scope: func(r *http.Request) (map[string]interface{}, error) {
+			n++
+			return map[string]interface{}{"nonce": fmt.Sprint("n", n)}, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"<script nonce=\"n1\" />", "<script nonce=\"n2\" />"} {
+		if w := serve(t, h, httptest.NewRequest("GET", "/page.html", nil)); w.Body.String() != expected {
+			t.Errorf("expected %q, got %q", expected, w.Body.String())
+		}
+	}
+}
+
+func TestBuildTransform(t *testing.T) {
+	src := writeTemplates(t, map[string]string{"index.haml": "%link{:href => \"/app.css\"}"})
+	out := t.TempDir()
+	if _, err := Build(src, out, nil, assets); err != nil {
+		t.Fatal(err)
+	}
+	if output := readOutput(t, out, "index.html"); output != "<link href=\"/app.3f2a.css\" />" {
+		t.Errorf("unexpected output %q", output)
+	}
+}
@@ -0,0 +1,100 @@
+package gohaml
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type checkAuthor struct {
+	Name string
+}
+
+func (self checkAuthor) Initials() string {
+	return self.Name[:1]
+}
+
+func (self *checkAuthor) Greet(greeting string) string {
+	return greeting + " " + self.Name
+}
+
+func (self *checkAuthor) Both() (string, error) {
+	return self.Name, nil
+}
+
+type checkPost struct {
+	Title  string
+	Author *checkAuthor
+	Tags   []string
+	Meta   map[string]checkAuthor
+	Extra  interface{}
+	Count  int
+}
+
+type checkPage struct {
+	Title string
+	Posts []checkPost
+	Seq   func(func(checkAuthor) bool)
+	Seq2  func(func(string, checkAuthor) bool)
+	Ch    <-chan int
+	Send  chan<- int
+}
+
+var typeCheckTests = []testcase{
+	testcase{"%h1= Title\n- for i, p := range Posts\n  %h2{:title => p.Title}= p.Author.Name\n  %p= p.Author.Initials", ""},
+	testcase{"%h1= Titel", "line 1: Titel: no field or method Titel in gohaml.checkPage"},
+	testcase{"- for i, p := range Posts\n  %p= p.Author.Nmae\n%p= p", "line 2: p.Author.Nmae: no field or method Nmae in gohaml.checkAuthor\nline 3: p: no field or method p in gohaml.checkPage"},
+	testcase{"- for i, p := range Posts\n  - for j, a := range p.Author\n    = a", "line 2: p.Author: cannot range over gohaml.checkAuthor"},
+	testcase{"- for i, c := range Title\n  = c.Foo\n- for i, n := range 3\n  = n.Foo\n- for i, n := range Posts.Count", "line 2: c.Foo: cannot look up Foo in string\nline 4: n.Foo: cannot look up Foo in int\nline 5: Posts.Count: cannot look up Count in []gohaml.checkPost"},
+	testcase{"- for i, v := range Seq\n  = v.Name\n- for k, v := range Seq2\n  = k.Foo\n- for i, v := range Ch\n  = v.Foo\n- for i, v := range Send", "line 4: k.Foo: cannot look up Foo in string\nline 6: v.Foo: cannot look up Foo in int\nline 7: Send: cannot range over chan<- int"},
+	testcase{"- for i, p := range Posts\n  - for k, a := range p.Meta\n    = a.Name\n    = k\n  = p.Extra.Anything.Goes\n  = p.Tags.Len", "line 6: p.Tags.Len: cannot look up Len in []string"},
+	testcase{"- for i, p := range Posts\n  = p.Author.Greet\n  = p.Author.Both", "line 2: p.Author.Greet: method Greet of *gohaml.checkAuthor takes 1 argument(s)\nline 3: p.Author.Both: method Both of *gohaml.checkAuthor returns 2 values"},
+	testcase{"- t := Title\n= t.Length\n- n := 1\n= n", "line 2: t.Length: cannot look up Length in string"},
+	testcase{"- def card(post)\n  %h2= post.Titel\n  - yield\n- for i, p := range Posts\n  +card(p)\n    %p= p.Author.Name\n    %p= i.Foo", "line 2: post.Titel: no field or method Titel in gohaml.checkPost\nline 7: i.Foo: cannot look up Foo in int"},
+	testcase{"- def loop(x)\n  +loop(x)\n  = x.Anything\n+loop(Title)", "line 3: x.Anything: cannot look up Anything in string"},
+	testcase{"- for p := range Posts\n  = p.Foo\n- for v := range Ch\n  = v.Foo\n- for _, p := range Posts\n  = p.Title\n  = loop.Parent.Number\n  = loop.Nmber\n- for range 2\n  = p", "line 2: p.Foo: cannot look up Foo in int\nline 4: v.Foo: cannot look up Foo in int\nline 8: loop.Nmber: no field or method Nmber in gohaml.Loop\nline 10: p: no field or method p in gohaml.checkPage"},
+	testcase{"- for i, p := range Posts\n  %p{p.Author}\n%p{Posts}\n%p{Missing}", "line 3: Posts: cannot use []gohaml.checkPost as attributes\nline 4: Missing: no field or method Missing in gohaml.checkPage"},
+	testcase{"- def unused(x)\n  = x.Anything\n  = Missing", "line 3: Missing: no field or method Missing in gohaml.checkPage"},
+}
+
+func TestCheckAgainst(t *testing.T) {
+	for i, io := range typeCheckTests {
+		engine, err := NewEngine(io.input)
+		if err != nil {
+			t.Errorf("(%d) Input %q\nunexpected error %s", i, io.input, err)
+			continue
+		}
+		var errs []string
+		for _, err := range engine.CheckAgainst(reflect.TypeOf(checkPage{})) {
+			errs = append(errs, err.Error())
+		}
+		output := strings.Join(errs, "\n")
+		if output != io.expected {
+			t.Errorf("(%d) Input    %q\nexpected %q\ngot      %q", i, io.input, io.expected, output)
+		}
+	}
+}
+
+func TestCheckAgainstMap(t *testing.T) {
+	engine, _ := NewEngine("%p= anything.at.all\n- for i, v := range list\n  = v.Name")
+	if errs := engine.CheckAgainst(reflect.TypeOf(map[string]interface{}{})); len(errs) != 0 {
+		t.Errorf("unexpected errors %v", errs)
+	}
+	engine, _ = NewEngine("%p= first.Name\n%p= first.Nope")
+	errs := engine.CheckAgainst(reflect.TypeOf(map[string]checkAuthor{}))
+	if len(errs) != 1 || errs[0].Error() != "line 2: first.Nope: no field or method Nope in gohaml.checkAuthor" {
+		t.Errorf("unexpected errors %v", errs)
+	}
+}
+
+func TestRenderMethods(t *testing.T) {
+	engine, _ := NewEngine("%p= author.Initials\n%p= ptr.Initials\n%p= ptr.Greet")
+	scope := map[string]interface{}{
+		"author": checkAuthor{"Jane"},
+		"ptr":    &checkAuthor{"Kim"},
+	}
+	expected := "<p>J</p>\n<p>K</p>\n<p />"
+	if output := engine.Render(scope); output != expected {
+		t.Errorf("expected %q\ngot      %q", expected, output)
+	}
+}
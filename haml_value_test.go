@@ -0,0 +1,109 @@
+package gohaml
+
+import (
+	"bytes"
+	"html/template"
+	"strings"
+	"testing"
+)
+
+type valueTestUser struct {
+	Name string
+	Tags []string
+}
+
+func (self *valueTestUser) Upper() string {
+	return strings.ToUpper(self.Name)
+}
+
+type valueTestPage struct {
+	Title string
+	User  *valueTestUser
+	Meta  map[string]string
+	count int
+}
+
+func (self valueTestPage) Heading() string {
+	return "# " + self.Title
+}
+
+type valueTestKey string
+
+var renderValueTests = []testcase{
+	testcase{"%h1= Title", "<h1>Home</h1>"},
+	testcase{"= Heading", "# Home"},
+	testcase{"%p= User.Name", "<p>Tom</p>"},
+	testcase{"= User.Upper", "TOM"},
+	testcase{"%p{:class => User.Tags}", "<p class=\"a b\" />"},
+	testcase{"- for _, tag := range User.Tags\n  = tag", "b\na"},
+	testcase{"= Meta.lang", "en"},
+	testcase{"- Title := \"Other\"\n= Title", "Other"},
+	testcase{"- def greet(name)\n  %p= name\n+greet(User.Name)", "<p>Tom</p>"},
+}
+
+func renderValueTestPage() *valueTestPage {
+	return &valueTestPage{
+		Title: "Home",
+		User:  &valueTestUser{"Tom", []string{"b", "a"}},
+		Meta:  map[string]string{"lang": "en"},
+	}
+}
+
+func TestRenderValue(t *testing.T) {
+	for i, io := range renderValueTests {
+		for _, data := range []interface{}{renderValueTestPage(), *renderValueTestPage()} {
+			engine, _ := NewEngine(io.input)
+			output, err := engine.RenderValue(data)
+			if err != nil {
+				t.Errorf("(%d) Input    %q\nunexpected error %s", i, io.input, err)
+			} else if output != io.expected {
+				t.Errorf("(%d) Input    %q\nexpected %q\ngot      %q", i, io.input, io.expected, output)
+			}
+		}
+	}
+
+	// assignments do not change the data.
+	page := renderValueTestPage()
+	engine, _ := NewEngine("- Title := \"Other\"")
+	engine.RenderValue(page)
+	if page.Title != "Home" {
+		t.Errorf("expected the data to be kept, got %q", page.Title)
+	}
+}
+
+func TestRenderValueMaps(t *testing.T) {
+	engine, _ := NewEngine("%p= name")
+	for _, data := range []interface{}{
+		map[string]interface{}{"name": "Tom"},
+		map[string]string{"name": "Tom"},
+		map[valueTestKey]string{"name": "Tom"},
+	} {
+		if output, err := engine.RenderValue(data); err != nil || output != "<p>Tom</p>" {
+			t.Errorf("%#v: expected %q, got %q %v", data, "<p>Tom</p>", output, err)
+		}
+	}
+	if _, err := engine.RenderValue(nil); err != nil {
+		t.Errorf("expected nil to be rendered without names, got %v", err)
+	}
+}
+
+func TestRenderValueErrors(t *testing.T) {
+	engine, _ := NewEngine("%p")
+	for _, data := range []interface{}{42, "name", []string{"a"}, map[int]string{1: "a"}} {
+		if _, err := engine.RenderValue(data); err == nil {
+			t.Errorf("expected an error for %#v", data)
+		}
+	}
+}
+
+func TestFuncMapValue(t *testing.T) {
+	engine, _ := NewEngine("%h1= Heading")
+	page := template.Must(template.New("page").Funcs(FuncMap("heading", engine)).Parse(`{{heading .}}`))
+	var buf bytes.Buffer
+	if err := page.Execute(&buf, renderValueTestPage()); err != nil || buf.String() != "<h1># Home</h1>" {
+		t.Errorf("expected %q, got %q %v", "<h1># Home</h1>", buf.String(), err)
+	}
+	if err := page.Execute(&buf, 42); err == nil {
+		t.Errorf("expected an error for an int")
+	}
+}
@@ -0,0 +1,31 @@
+//go:build echo
+
+package hamlhttp
+
+import (
+	"io"
+
+	"github.com/labstack/echo/v4"
+	"github.com/realistschuckle/gohaml"
+)
+
+var _ echo.Renderer = EchoRenderer{}
+
+/*
+EchoRenderer is the Renderer of an echo.Echo that renders the templates of a
+gohaml.Renderer, so that echo handlers send pages with c.Render:
+
+	e.Renderer = hamlhttp.EchoRenderer{views}
+	...
+	return c.Render(http.StatusOK, "index", data)
+
+It is built with the echo build tag.
+*/
+type EchoRenderer struct {
+	Renderer *gohaml.Renderer
+}
+
+// Render renders the template name with data to w.
+func (self EchoRenderer) Render(w io.Writer, name string, data interface{}, c echo.Context) error {
+	return self.Renderer.Render(w, name, data)
+}
@@ -0,0 +1,18 @@
+//go:build echo
+
+package hamlhttp
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEchoRenderer(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (EchoRenderer{newRenderer(t)}).Render(&buf, "hello", map[string]string{"Name": "<Jo>"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "<body><p>&lt;Jo&gt;</p></body>"; buf.String() != expected {
+		t.Errorf("expected %q\ngot      %q", expected, buf.String())
+	}
+}
@@ -0,0 +1,29 @@
+//go:build gin
+
+package hamlhttp
+
+import (
+	"github.com/gin-gonic/gin/render"
+	"github.com/realistschuckle/gohaml"
+)
+
+var _ render.HTMLRender = GinRender{}
+
+/*
+GinRender is the HTMLRender of a gin.Engine that renders the templates of a
+gohaml.Renderer, so that gin handlers send pages with c.HTML:
+
+	router.HTMLRender = hamlhttp.GinRender{views}
+	...
+	c.HTML(http.StatusOK, "index", data)
+
+It is built with the gin build tag.
+*/
+type GinRender struct {
+	Renderer *gohaml.Renderer
+}
+
+// Instance returns the Page rendering the template name with data.
+func (self GinRender) Instance(name string, data interface{}) render.Render {
+	return Page{self.Renderer, name, data}
+}
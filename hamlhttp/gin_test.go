@@ -0,0 +1,21 @@
+//go:build gin
+
+package hamlhttp
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGinRender(t *testing.T) {
+	w := httptest.NewRecorder()
+	if err := (GinRender{newRenderer(t)}).Instance("hello", map[string]string{"Name": "<Jo>"}).Render(w); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "<body><p>&lt;Jo&gt;</p></body>"; w.Body.String() != expected {
+		t.Errorf("expected %q\ngot      %q", expected, w.Body.String())
+	}
+	if w.Header().Get("Content-Type") != contentType {
+		t.Errorf("expected Content-Type %q, got %q", contentType, w.Header().Get("Content-Type"))
+	}
+}
@@ -0,0 +1,63 @@
+// Package hamlhttp sends pages rendered by a gohaml.Renderer as HTTP
+// responses, for net/http handlers and routers such as chi, and for web
+// frameworks that take a value to render the response with. Building with
+// the echo or gin tag adds EchoRenderer and GinRender, which plug a
+// gohaml.Renderer into those frameworks.
+package hamlhttp
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/realistschuckle/gohaml"
+)
+
+const contentType = "text/html; charset=utf-8"
+
+// HTML renders the template name with data and sends it with the given
+// status. A template that fails to render is answered with 500 and its
+// error is returned.
+func HTML(w http.ResponseWriter, status int, r *gohaml.Renderer, name string, data interface{}) error {
+	var buf bytes.Buffer
+	if err := r.Render(&buf, name, data); err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return err
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+/*
+Page is a page to render as the body of a response whose status is written by the
+caller. Its methods are those of the Render interface of gin's render package, so
+that a gin handler can send it with c.Render, without the gin build tag:
+
+	c.Render(http.StatusOK, hamlhttp.Page{views, "index", data})
+*/
+type Page struct {
+	Renderer *gohaml.Renderer
+	Name     string
+	Data     interface{}
+}
+
+// Render renders the page and writes it to w, writing nothing when the
+// template fails to render.
+func (self Page) Render(w http.ResponseWriter) error {
+	var buf bytes.Buffer
+	if err := self.Renderer.Render(&buf, self.Name, self.Data); err != nil {
+		return err
+	}
+	self.WriteContentType(w)
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// WriteContentType sets the Content-Type header of the response to HTML
+// unless it is set already.
+func (self Page) WriteContentType(w http.ResponseWriter) {
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+}
@@ -0,0 +1,77 @@
+package hamlhttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/realistschuckle/gohaml"
+)
+
+func newRenderer(t *testing.T) *gohaml.Renderer {
+	dir := t.TempDir()
+	for name, src := range map[string]string{"layout.haml": "%body= content", "hello.haml": "%p= Name"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	loader, err := gohaml.NewFileSystemLoader(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := gohaml.NewRenderer(loader)
+	r.Layout = "layout"
+	r.EscapeHTML = true
+	return r
+}
+
+func TestHTML(t *testing.T) {
+	views := newRenderer(t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hello", func(w http.ResponseWriter, r *http.Request) {
+		HTML(w, http.StatusCreated, views, "hello", struct{ Name string }{r.URL.Query().Get("name")})
+	})
+	mux.HandleFunc("/missing", func(w http.ResponseWriter, r *http.Request) {
+		if err := HTML(w, http.StatusOK, views, "missing", nil); err == nil {
+			t.Errorf("expected an error for a missing template")
+		}
+	})
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/hello?name=<Tom>", nil))
+	if w.Code != http.StatusCreated || w.Header().Get("Content-Type") != contentType || w.Body.String() != "<body><p>&lt;Tom&gt;</p></body>" {
+		t.Errorf("unexpected response %d %q %q", w.Code, w.Header().Get("Content-Type"), w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/missing", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected 500, got %d", w.Code)
+	}
+}
+
+func TestPage(t *testing.T) {
+	views := newRenderer(t)
+	w := httptest.NewRecorder()
+	if err := (Page{views, "hello", map[string]interface{}{"Name": "Tom"}}).Render(w); err != nil {
+		t.Fatal(err)
+	}
+	if w.Header().Get("Content-Type") != contentType || w.Body.String() != "<body><p>Tom</p></body>" {
+		t.Errorf("unexpected response %q %q", w.Header().Get("Content-Type"), w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	if err := (Page{views, "missing", nil}).Render(w); err == nil || w.Body.Len() != 0 {
+		t.Errorf("expected an error and no body, got %v %q", err, w.Body.String())
+	}
+
+	// a type already set is kept.
+	w = httptest.NewRecorder()
+	w.Header().Set("Content-Type", "application/xhtml+xml")
+	Page{views, "hello", nil}.WriteContentType(w)
+	if w.Header().Get("Content-Type") != "application/xhtml+xml" {
+		t.Errorf("expected the Content-Type to be kept, got %q", w.Header().Get("Content-Type"))
+	}
+}
@@ -0,0 +1,421 @@
+package gohaml
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"strings"
+)
+
+// HTMLToHaml converts HTML markup into a HAML template that renders
+// equivalent markup. Elements are written with the %tag, #id and .class
+// shorthands and {} attributes; whatever the parser cannot express, such as
+// comments or attribute values containing commas, is kept as plain HTML, and
+// so are pre and textarea elements, on a single line, so that their
+// whitespace is rendered as it was.
+func HTMLToHaml(input string) (output string, err error) {
+	var tokens []htmlToken
+	if tokens, err = tokenizeHTML(input); err != nil {
+		return
+	}
+	root := buildHTMLTree(tokens)
+	var buf bytes.Buffer
+	for _, n := range root.children {
+		writeHamlNode(&buf, n, "")
+	}
+	output = strings.TrimSuffix(buf.String(), "\n")
+	return
+}
+
+const (
+	htmlText = iota
+	htmlStartTag
+	htmlEndTag
+	htmlSelfClosingTag
+	htmlComment
+	htmlDoctype
+)
+
+type htmlAttr struct {
+	key     string
+	value   string
+	noValue bool
+}
+
+type htmlToken struct {
+	kind  int
+	data  string
+	attrs []htmlAttr
+}
+
+type htmlNode struct {
+	kind     int
+	data     string
+	attrs    []htmlAttr
+	children []*htmlNode
+	parent   *htmlNode
+}
+
+var voidElements = []string{"area", "base", "br", "col", "embed", "hr", "img", "input", "keygen", "link", "meta", "param", "source", "track", "wbr"}
+
+var rawTextElements = []string{"script", "style"}
+
+// preservedElements are the elements whose whitespace is part of their
+// content. They are kept as HTML on a single line, with their line breaks
+// and tabs written as character references.
+var preservedElements = []string{"pre", "textarea", "listing"}
+
+// impliedEndTags lists the elements whose start tag closes an open sibling
+// of one of the given names, as in <li>one<li>two.
+var impliedEndTags = map[string][]string{
+	"li":     []string{"li"},
+	"p":      []string{"p"},
+	"option": []string{"option"},
+	"tr":     []string{"tr", "td", "th"},
+	"td":     []string{"td", "th"},
+	"th":     []string{"td", "th"},
+	"dt":     []string{"dt", "dd"},
+	"dd":     []string{"dt", "dd"},
+}
+
+func tokenizeHTML(input string) (tokens []htmlToken, err error) {
+	for i := 0; i < len(input); {
+		if input[i] != '<' {
+			end := strings.IndexByte(input[i:], '<')
+			if end < 0 {
+				end = len(input) - i
+			}
+			tokens = append(tokens, htmlToken{kind: htmlText, data: html.UnescapeString(input[i : i+end])})
+			i += end
+			continue
+		}
+
+		rest := input[i:]
+		switch {
+		case strings.HasPrefix(rest, "<!--"):
+			end := strings.Index(rest, "-->")
+			if end < 0 {
+				return nil, fmt.Errorf("html: unterminated comment at offset %d", i)
+			}
+			tokens = append(tokens, htmlToken{kind: htmlComment, data: rest[4:end]})
+			i += end + 3
+		case strings.HasPrefix(rest, "<!") || strings.HasPrefix(rest, "<?"):
+			end := strings.IndexByte(rest, '>')
+			if end < 0 {
+				return nil, fmt.Errorf("html: unterminated declaration at offset %d", i)
+			}
+			tokens = append(tokens, htmlToken{kind: htmlDoctype, data: rest[:end+1]})
+			i += end + 1
+		case len(rest) > 1 && (isTagNameStart(rest[1]) || rest[1] == '/' && len(rest) > 2 && isTagNameStart(rest[2])):
+			var tok htmlToken
+			var n int
+			if tok, n, err = tokenizeTag(rest); err != nil {
+				return nil, fmt.Errorf("html: %s at offset %d", err, i)
+			}
+			tokens = append(tokens, tok)
+			i += n
+			if tok.kind == htmlStartTag && contains(tok.data, rawTextElements) {
+				end := strings.Index(strings.ToLower(input[i:]), "</"+tok.data)
+				if end < 0 {
+					end = len(input) - i
+				}
+				if end > 0 {
+					tokens = append(tokens, htmlToken{kind: htmlText, data: input[i : i+end]})
+				}
+				i += end
+			}
+		default:
+			tokens = append(tokens, htmlToken{kind: htmlText, data: "<"})
+			i++
+		}
+	}
+	return
+}
+
+func isTagNameStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// tokenizeTag reads a start or end tag at the beginning of input and returns
+// it together with its length.
+func tokenizeTag(input string) (tok htmlToken, n int, err error) {
+	i := 1
+	tok.kind = htmlStartTag
+	if input[i] == '/' {
+		tok.kind = htmlEndTag
+		i++
+	}
+	start := i
+	for i < len(input) && !isSpace(input[i]) && input[i] != '>' && input[i] != '/' {
+		i++
+	}
+	tok.data = strings.ToLower(input[start:i])
+
+	for {
+		for i < len(input) && isSpace(input[i]) {
+			i++
+		}
+		if i >= len(input) {
+			return tok, 0, fmt.Errorf("unterminated tag <%s", tok.data)
+		}
+		if input[i] == '>' {
+			return tok, i + 1, nil
+		}
+		if input[i] == '/' {
+			if i+1 < len(input) && input[i+1] == '>' {
+				if tok.kind == htmlStartTag {
+					tok.kind = htmlSelfClosingTag
+				}
+				return tok, i + 2, nil
+			}
+			i++
+			continue
+		}
+
+		start = i
+		for i < len(input) && !isSpace(input[i]) && input[i] != '>' && input[i] != '=' && !(input[i] == '/' && i+1 < len(input) && input[i+1] == '>') {
+			i++
+		}
+		attr := htmlAttr{key: strings.ToLower(input[start:i]), noValue: true}
+		for i < len(input) && isSpace(input[i]) {
+			i++
+		}
+		if i < len(input) && input[i] == '=' {
+			i++
+			for i < len(input) && isSpace(input[i]) {
+				i++
+			}
+			attr.noValue = false
+			if i < len(input) && (input[i] == '"' || input[i] == '\'') {
+				end := strings.IndexByte(input[i+1:], input[i])
+				if end < 0 {
+					return tok, 0, fmt.Errorf("unterminated attribute value in <%s", tok.data)
+				}
+				attr.value = input[i+1 : i+1+end]
+				i += end + 2
+			} else {
+				start = i
+				for i < len(input) && !isSpace(input[i]) && input[i] != '>' {
+					i++
+				}
+				attr.value = input[start:i]
+			}
+			attr.value = html.UnescapeString(attr.value)
+		}
+		tok.attrs = append(tok.attrs, attr)
+	}
+}
+
+func buildHTMLTree(tokens []htmlToken) *htmlNode {
+	root := &htmlNode{}
+	cur := root
+	for _, tok := range tokens {
+		switch tok.kind {
+		case htmlStartTag, htmlSelfClosingTag:
+			for cur != root && contains(cur.data, impliedEndTags[tok.data]) {
+				cur = cur.parent
+			}
+			n := &htmlNode{kind: htmlStartTag, data: tok.data, attrs: tok.attrs, parent: cur}
+			cur.children = append(cur.children, n)
+			if tok.kind == htmlStartTag && !contains(tok.data, voidElements) {
+				cur = n
+			}
+		case htmlEndTag:
+			// close the nearest open element of that name, and every element
+			// left open inside of it.
+			for n := cur; n != root; n = n.parent {
+				if n.data == tok.data {
+					cur = n.parent
+					break
+				}
+			}
+		default:
+			cur.children = append(cur.children, &htmlNode{kind: tok.kind, data: tok.data, parent: cur})
+		}
+	}
+	return root
+}
+
+func writeHamlNode(buf *bytes.Buffer, n *htmlNode, indent string) {
+	switch n.kind {
+	case htmlText:
+		raw := n.parent != nil && contains(n.parent.data, rawTextElements)
+		for _, line := range strings.Split(n.data, "\n") {
+			if line = strings.TrimSpace(line); line == "" {
+				continue
+			}
+			if !raw {
+				line = escapeHTMLText(line)
+			}
+			writeHamlLine(buf, indent, hamlText(line))
+		}
+	case htmlComment:
+		writeHamlLine(buf, indent, hamlText("<!--"+n.data+"-->"))
+	case htmlDoctype:
+		if strings.EqualFold(strings.Join(strings.Fields(n.data), " "), "<!DOCTYPE html>") {
+			writeHamlLine(buf, indent, "!!! 5")
+		} else {
+			writeHamlLine(buf, indent, hamlText(n.data))
+		}
+	case htmlStartTag:
+		writeHamlElement(buf, n, indent)
+	}
+}
+
+func writeHamlElement(buf *bytes.Buffer, n *htmlNode, indent string) {
+	if contains(n.data, preservedElements) {
+		var markup bytes.Buffer
+		writeHTML(&markup, n)
+		writeHamlLine(buf, indent, preservedText.Replace(markup.String()))
+		return
+	}
+	tag, ok := hamlTag(n)
+	if !ok {
+		// keep the tags as plain text and the content as siblings.
+		writeHamlLine(buf, indent, hamlText(startTag(n)))
+		for _, child := range n.children {
+			writeHamlNode(buf, child, indent)
+		}
+		if !contains(n.data, voidElements) {
+			writeHamlLine(buf, indent, hamlText("</"+n.data+">"))
+		}
+		return
+	}
+
+	if len(n.children) == 1 && n.children[0].kind == htmlText {
+		text := strings.TrimSpace(n.children[0].data)
+		if !strings.Contains(text, "\n") && !contains(n.data, rawTextElements) {
+			text = escapeHTMLText(text)
+			if text != "" && !strings.HasSuffix(text, "<") {
+				writeHamlLine(buf, indent, tag+" "+text)
+				return
+			}
+		}
+	}
+	writeHamlLine(buf, indent, tag)
+	for _, child := range n.children {
+		writeHamlNode(buf, child, indent+"  ")
+	}
+}
+
+// startTag returns the start tag of an element as HTML.
+func startTag(n *htmlNode) string {
+	var open bytes.Buffer
+	open.WriteString("<" + n.data)
+	for _, attr := range n.attrs {
+		open.WriteString(" " + attr.key)
+		if !attr.noValue {
+			open.WriteString("=\"" + html.EscapeString(attr.value) + "\"")
+		}
+	}
+	open.WriteString(">")
+	return open.String()
+}
+
+// writeHTML writes a node and its content back as HTML.
+func writeHTML(buf *bytes.Buffer, n *htmlNode) {
+	switch n.kind {
+	case htmlText:
+		if n.parent != nil && contains(n.parent.data, rawTextElements) {
+			buf.WriteString(n.data)
+		} else {
+			buf.WriteString(escapeHTMLText(n.data))
+		}
+	case htmlComment:
+		buf.WriteString("<!--" + n.data + "-->")
+	case htmlDoctype:
+		buf.WriteString(n.data)
+	case htmlStartTag:
+		buf.WriteString(startTag(n))
+		for _, child := range n.children {
+			writeHTML(buf, child)
+		}
+		if !contains(n.data, voidElements) {
+			buf.WriteString("</" + n.data + ">")
+		}
+	}
+}
+
+var preservedText = strings.NewReplacer("\r", "&#13;", "\n", "&#10;", "\t", "&#9;")
+
+// hamlTag returns the HAML notation of the element's start tag, or false if
+// its attributes cannot be written in HAML.
+func hamlTag(n *htmlNode) (string, bool) {
+	var id string
+	var classes []string
+	var attrs []string
+	for _, attr := range n.attrs {
+		value := html.EscapeString(attr.value)
+		switch {
+		case attr.key == "id" && id == "" && isHamlName(attr.value):
+			id = attr.value
+		case attr.key == "class" && len(strings.Fields(attr.value)) > 0 && allHamlNames(strings.Fields(attr.value)):
+			classes = append(classes, strings.Fields(attr.value)...)
+		case attr.noValue:
+			attrs = append(attrs, ":"+attr.key+" => true")
+		case strings.ContainsAny(attr.value, ",}") || strings.ContainsAny(attr.key, ",}=\"") || attr.value == "true" || attr.value == "false":
+			return "", false
+		default:
+			attrs = append(attrs, ":"+attr.key+" => \""+value+"\"")
+		}
+	}
+
+	var tag bytes.Buffer
+	if n.data != "div" || (id == "" && len(classes) == 0) {
+		tag.WriteString("%" + n.data)
+	}
+	if id != "" {
+		tag.WriteString("#" + id)
+	}
+	for _, class := range classes {
+		tag.WriteString("." + class)
+	}
+	if len(attrs) > 0 {
+		tag.WriteString("{" + strings.Join(attrs, ", ") + "}")
+	}
+	return tag.String(), true
+}
+
+func isHamlName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+func allHamlNames(names []string) bool {
+	for _, name := range names {
+		if !isHamlName(name) {
+			return false
+		}
+	}
+	return true
+}
+
+func escapeHTMLText(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// hamlText escapes a line of plain text that the parser would otherwise
+// read as markup or code.
+func hamlText(line string) string {
+	if strings.ContainsRune("%#.-=\\!+", rune(line[0])) {
+		return "\\" + line
+	}
+	return line
+}
+
+func writeHamlLine(buf *bytes.Buffer, indent string, line string) {
+	buf.WriteString(indent)
+	buf.WriteString(line)
+	buf.WriteString("\n")
+}
@@ -0,0 +1,96 @@
+package gohaml
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var htmlToHamlTests = []testcase{
+	testcase{"<p>Hello</p>", "%p Hello"},
+	testcase{"<div class=\"a b\" id=\"main\"><br></div>", "#main.a.b\n  %br"},
+	testcase{"<div></div>", "%div"},
+	testcase{"<a href=\"/x?a=1&amp;b=2\" title='Say \"hi\"'>Go</a>", "%a{:href => \"/x?a=1&amp;b=2\", :title => \"Say &#34;hi&#34;\"} Go"},
+	testcase{"<input type=checkbox checked>", "%input{:type => \"checkbox\", :checked => true}"},
+	testcase{"<!DOCTYPE html>\n<html>\n  <body>\n    <p>\n      - not code\n      5 &lt; 6\n    </p>\n  </body>\n</html>", "!!! 5\n%html\n  %body\n    %p\n      \\- not code\n      5 &lt; 6"},
+	testcase{"<!-- note --><p data-x=\"a,b\">x</p>", "<!-- note -->\n<p data-x=\"a,b\">\nx\n</p>"},
+	testcase{"<ul><li>one<li>two</ul>", "%ul\n  %li one\n  %li two"},
+	testcase{"<script>\nif (a < b) { go() }\n</script>", "%script\n  if (a < b) { go() }"},
+	testcase{"<P CLASS=\"x\">Hi<br/>there</P>", "%p.x\n  Hi\n  %br\n  there"},
+	testcase{"<div><textarea name=\"a\">  line1\n  line2</textarea></div>", "%div\n  <textarea name=\"a\">  line1&#10;  line2</textarea>"},
+}
+
+func TestHTMLToHaml(t *testing.T) {
+	for i, io := range htmlToHamlTests {
+		output, err := HTMLToHaml(io.input)
+		if err != nil {
+			t.Errorf("(%d) Input %q\nunexpected error %s", i, io.input, err)
+			continue
+		}
+		if output != io.expected {
+			t.Errorf("(%d) Input    %q\nexpected %q\ngot      %q", i, io.input, io.expected, output)
+		}
+	}
+}
+
+var betweenTags = regexp.MustCompile(`>\s+|\s+<`)
+
+func normalizeMarkup(s string) string {
+	return strings.TrimSpace(betweenTags.ReplaceAllStringFunc(s, strings.TrimSpace))
+}
+
+func TestHTMLToHamlRendersEquivalentMarkup(t *testing.T) {
+	inputs := []string{
+		"<html><head><title>A &amp; B</title></head><body><h1 id=\"top\" class=\"big title\">Hi</h1><p>Text</p><img src=\"a.png\" alt=\"\"></body></html>",
+		"<form action=\"/go\" method=\"post\"><input type=\"text\" name=\"q\"><input type=\"checkbox\" checked=\"checked\"></form>",
+		"<div><p class=\"x\" data-list=\"1,2\">Odd</p><!-- comment --></div>",
+	}
+	for i, input := range inputs {
+		haml, err := HTMLToHaml(input)
+		if err != nil {
+			t.Errorf("(%d) Input %q\nunexpected error %s", i, input, err)
+			continue
+		}
+		engine, err := NewEngine(haml)
+		if err != nil {
+			t.Errorf("(%d) HAML %q\nunexpected error %s", i, haml, err)
+			continue
+		}
+		engine.Autoclose = false
+		output := engine.Render(map[string]interface{}{})
+		if normalizeMarkup(output) != normalizeMarkup(input) {
+			t.Errorf("(%d) Input    %q\nHAML     %q\nrendered %q", i, input, haml, output)
+		}
+	}
+}
+
+// TestHTMLToHamlPreservesWhitespace checks that the content of elements
+// where whitespace matters renders the same characters.
+func TestHTMLToHamlPreservesWhitespace(t *testing.T) {
+	for i, io := range []testcase{
+		testcase{"<textarea>  line1\n  line2</textarea>", "<textarea>  line1&#10;  line2</textarea>"},
+		testcase{"<div>\n<pre class=\"go\"><code>if a &lt; b {\n\treturn\n}</code>\n</pre>\n</div>", "<div>\n\t<pre class=\"go\"><code>if a &lt; b {&#10;&#9;return&#10;}</code>&#10;</pre>\n</div>"},
+	} {
+		haml, err := HTMLToHaml(io.input)
+		if err != nil {
+			t.Errorf("(%d) Input %q\nunexpected error %s", i, io.input, err)
+			continue
+		}
+		engine, err := NewEngine(haml)
+		if err != nil {
+			t.Errorf("(%d) HAML %q\nunexpected error %s", i, haml, err)
+			continue
+		}
+		if output := engine.Render(map[string]interface{}{}); output != io.expected {
+			t.Errorf("(%d) Input    %q\nexpected %q\ngot      %q", i, io.input, io.expected, output)
+		}
+	}
+}
+
+func TestHTMLToHamlErrors(t *testing.T) {
+	for _, input := range []string{"<p", "<!-- open", "<a href=\"x>y"} {
+		if _, err := HTMLToHaml(input); err == nil {
+			t.Errorf("Input %q\nexpected an error", input)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package gohaml
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"reflect"
+	"strings"
+)
+
+// htmlType is the type of html/template whose values are written as they
+// are when the engine escapes HTML. The other types of html/template, such
+// as template.URL, are only safe in some contexts and are escaped.
+var htmlType = reflect.TypeOf(template.HTML(""))
+
+// preEscaped tells whether a value, or the value it points to, is a
+// template.HTML.
+func preEscaped(v reflect.Value) bool {
+	for (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && !v.IsNil() {
+		if v.Type() == htmlType {
+			return true
+		}
+		v = v.Elem()
+	}
+	return v.IsValid() && v.Type() == htmlType
+}
+
+// validAttrName tells whether name can be written as the name of an
+// attribute: it is not empty and holds no spaces, quotes, control
+// characters or any of the characters that end a name or a tag.
+func validAttrName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if r <= ' ' || r == 0x7f || strings.ContainsRune("\"'<>/=&", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// formatText converts a value to text with formatValue, escaping it when
+// escape is set and the value is not preEscaped.
+func formatText(v reflect.Value, formatters map[reflect.Type]Formatter, escape bool) string {
+	s := formatValue(v, formatters)
+	if escape && !preEscaped(v) {
+		s = html.EscapeString(s)
+	}
+	return s
+}
+
+// RenderHTML renders the template like Render does, as markup that
+// html/template writes without escaping it. Unless EscapeHTML is set, the
+// scope must not hold text from untrusted sources.
+func (self *Engine) RenderHTML(scope map[string]interface{}) template.HTML {
+	return template.HTML(self.Render(scope))
+}
+
+/*
+FuncMap returns the functions to add to an html/template with its Funcs method in order
+to render engine under the given name. The function takes the scope as an optional
+argument, which can be any value that RenderValue accepts, and returns the markup as
+template.HTML:
+
+	funcs := gohaml.FuncMap("sidebar", sidebar)
+	t := template.Must(template.New("page").Funcs(funcs).Parse(`<body>{{sidebar .}}</body>`))
+
+A template that fails to render stops the execution of the html/template with an error.
+*/
+func FuncMap(name string, engine *Engine) template.FuncMap {
+	return template.FuncMap{name: func(data ...interface{}) (output template.HTML, err error) {
+		if len(data) > 1 {
+			return "", fmt.Errorf("gohaml: %s takes a single scope, got %d", name, len(data))
+		}
+		var root reflect.Value
+		if len(data) == 1 {
+			if root, err = rootValue(data[0]); err != nil {
+				return
+			}
+		}
+		var s string
+		s, err = renderRoot(engine, make(map[string]interface{}), root)
+		return template.HTML(s), err
+	}}
+}
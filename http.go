@@ -1,8 +1,16 @@
 package gohaml
 
 import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
+	"time"
 )
 
 // create an http.Handler that loads haml files from locations relative
@@ -12,20 +20,80 @@ import (
 // /bla.html              -> ${base}/bla.haml
 // /bla/bla/dingdong.html -> ${base}/bla/bla/dingdong.haml
 // /bla/bla/              -> ${base}/bla/bla/index.haml
+//
+// Templates and directories whose names are in brackets match any segment
+// of the path, and the segment is added to the scope under the name in the
+// brackets; a template named [...name].haml matches the rest of the path:
+//
+// /users/42.html         -> ${base}/users/[id].haml with id "42"
+// /users/42/posts/       -> ${base}/users/[id]/posts/index.haml
+// /docs/a/b.html         -> ${base}/docs/[...slug].haml with slug "a/b"
+//
+// Paths without a template are answered with ${base}/404.haml, and errors
+// with ${base}/500.haml, when they exist; both are rendered with the status
+// code as status and, for errors, the error as error.
+//
+// Pages are rendered with the request scope described in HandlerOptions.
 func NewHamlHandler(base string) (hndl http.Handler, err error) {
+	return NewHamlHandlerWithOptions(base, HandlerOptions{})
+}
+
+// ScopeFunc returns the values a page is rendered with for a request; the
+// segments matched by its route are available through PathParam. An
+// error that has a StatusCode() int method is answered with that status,
+// any other error with 500 Internal Server Error.
+type ScopeFunc func(*http.Request) (map[string]interface{}, error)
+
+/*
+HandlerOptions controls the handler returned by NewHamlHandlerWithOptions.
+
+Every page is rendered with a scope holding the following values of the request,
+to which the values returned by the Scope function, if any, are added.
+
+	request   the *http.Request
+	path      the path of the URL
+	query     the first value of every query parameter, by name
+	form      the first value of every form field and query parameter, by name
+	headers   the first value of every header, by canonical name
+	cookies   the value of every cookie, by name
+
+The Cache field turns on the output cache when it is not nil, and the Compression field
+the compression of pages.
+
+The Transformers field contains the transformers run on every template when it is
+loaded, as with Engine.Transform.
+*/
+type HandlerOptions struct {
+	Scope        ScopeFunc
+	Cache        *CacheOptions
+	Compression  *CompressionOptions
+	Transformers []Transformer
+}
+
+// NewHamlHandlerWithOptions returns a handler like NewHamlHandler that
+// renders pages with the scope built for each request as opts describes.
+func NewHamlHandlerWithOptions(base string, opts HandlerOptions) (hndl http.Handler, err error) {
 	var l Loader
-	if l, err = NewFileSystemLoader(base); err != nil {
+	if l, err = NewFileSystemLoader(base, opts.Transformers...); err != nil {
 		return
 	}
-	return &httpHamlHandler{l}, nil
+	hamlHandler := &httpHamlHandler{base: base, loader: l, opts: opts}
+	if opts.Cache != nil {
+		if opts.Cache.Key == nil {
+			return nil, errors.New("gohaml: CacheOptions has no Key")
+		}
+		hamlHandler.cache = newPageCache(*opts.Cache)
+	}
+	return hamlHandler, nil
 }
 
 type httpHamlHandler struct {
+	base   string
 	loader Loader
+	opts   HandlerOptions
+	cache  *pageCache
 }
 
-var defaultScope map[string]interface{}
-
 func adjustSuffix(path string) string {
 	const htmlExt = ".html"
 	const htmExt = ".htm"
@@ -63,10 +131,272 @@ func (h *httpHamlHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusMovedPermanently)
 		return
 	}
-	path = adjustSuffix(path)
-	if engine, err := h.loader.Load(path); err != nil {
+	id, params, ok := route(h.base, adjustSuffix(path))
+	if !ok {
+		h.fail(w, r, &statusError{http.StatusNotFound, errors.New("no template for " + path)})
+		return
+	}
+	r = withParams(r, params)
+	fi, err := os.Stat(filepath.Join(h.base, filepath.FromSlash(id)))
+	if err != nil {
+		h.fail(w, r, err)
+		return
+	}
+	// the scope is built before looking for a kept page, so that a Scope
+	// function rejecting the request is never bypassed by the cache.
+	scope, err := h.scope(r)
+	if err == nil && h.opts.Scope != nil {
+		var extra map[string]interface{}
+		extra, err = h.opts.Scope(r)
+		for key, value := range extra {
+			scope[key] = value
+		}
+	}
+	if err != nil {
+		h.fail(w, r, err)
+		return
+	}
+	var key string
+	var version time.Time
+	if h.cache != nil {
+		if key = h.cache.opts.Key(r); key != "" {
+			if version, err = h.version(id, fi); err != nil {
+				h.fail(w, r, err)
+				return
+			}
+			if p := h.cache.get(key, version); p != nil {
+				h.send(w, r, p)
+				return
+			}
+		}
+	}
+
+	engine, err := h.loader.Load(id)
+	if err != nil {
+		h.fail(w, r, err)
+		return
+	}
+
+	// static pages are identified by their template, which lets
+	// conditional requests for them be answered without rendering.
+	p := new(page)
+	if isStatic(engine.ast.nodes) {
+		p.modtime = fi.ModTime()
+		p.etag = etag(fmt.Sprintf("%s %d %d", id, fi.Size(), p.modtime.UnixNano()))
+		if tag, ok := notModified(r, p.etag, p.modtime); ok {
+			if h.opts.Compression != nil {
+				w.Header().Add("Vary", "Accept-Encoding")
+			}
+			w.Header().Set("ETag", tag)
+			w.Header().Set("Last-Modified", p.modtime.UTC().Format(http.TimeFormat))
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	if p.body, err = render(engine, scope); err != nil {
+		h.fail(w, r, err)
+		return
+	}
+	if p.etag == "" {
+		p.etag = etag(p.body)
+	}
+	if key != "" {
+		h.cache.put(key, version, p)
+	}
+	h.send(w, r, p)
+}
+
+// version returns the time the markup of the template id, whose info is
+// given, last changed, including the changes to the partials of the loader.
+func (h *httpHamlHandler) version(id string, fi os.FileInfo) (time.Time, error) {
+	if l, ok := h.loader.(*fileSystemLoader); ok {
+		return l.modTime(id)
+	}
+	return fi.ModTime(), nil
+}
+
+// send writes a page, or 304 Not Modified when the request is conditional
+// and matches the page.
+func (h *httpHamlHandler) send(w http.ResponseWriter, r *http.Request, p *page) {
+	body, tag := p.body, p.etag
+	if c := h.opts.Compression; c != nil {
+		w.Header().Add("Vary", "Accept-Encoding")
+		minSize := c.MinSize
+		if minSize == 0 {
+			minSize = 1024
+		}
+		if len(body) >= minSize {
+			if encoding := negotiateEncoding(r.Header.Get("Accept-Encoding")); encoding != "" {
+				body, tag = p.encoded(encoding, c.Level), variantTag(tag, encoding)
+				w.Header().Set("Content-Encoding", encoding)
+			}
+		}
+	}
+	w.Header().Set("ETag", tag)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	http.ServeContent(w, r, "", p.modtime, strings.NewReader(body))
+}
+
+// etag returns a strong entity tag for content.
+func etag(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return fmt.Sprintf("\"%x\"", sum[:16])
+}
+
+// notModified tells whether the conditional headers of a GET or HEAD
+// request match the page in any of its encodings, following the precedence
+// of RFC 7232, and returns the ETag of the matching encoding.
+func notModified(r *http.Request, tag string, modtime time.Time) (matched string, ok bool) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		return
+	}
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, t := range strings.Split(inm, ",") {
+			t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+			if t == "*" || t == tag {
+				return tag, true
+			}
+			for _, encoding := range encodings {
+				if t == variantTag(tag, encoding) {
+					return t, true
+				}
+			}
+		}
+		return
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	return tag, err == nil && !modtime.Truncate(time.Second).After(since)
+}
+
+// isStatic tells whether nodes render the same markup for every scope.
+func isStatic(nodes []inode) bool {
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case *node:
+			if n._remainder.needsResolution || n._name == "include" {
+				return false
+			}
+			for _, attr := range n._attrs {
+				if attr.key.needsResolution || attr.value.needsResolution {
+					return false
+				}
+			}
+		case *rangenode:
+			if n._rhs.needsResolution {
+				return false
+			}
+		case *vdeclassnode, *callnode:
+			return false
+		}
+		if !isStatic(childrenOf(n)) {
+			return false
+		}
+	}
+	return true
+}
+
+// render renders a page, turning a panic into an error so that the handler
+// can answer with an error page.
+func render(engine *Engine, scope map[string]interface{}) (output string, err error) {
+	return renderRoot(engine, scope, reflect.Value{})
+}
+
+// renderRoot is render for a page whose names are also looked up in a
+// root value, as with RenderValue.
+func renderRoot(engine *Engine, scope map[string]interface{}, root reflect.Value) (output string, err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("gohaml: rendering failed: %v", v)
+		}
+	}()
+	return engine.render(scope, root)
+}
+
+// fail answers a request with the status of err, or 500, using the error
+// page for the status when there is one.
+func (h *httpHamlHandler) fail(w http.ResponseWriter, r *http.Request, err error) {
+	code := http.StatusInternalServerError
+	if serr, ok := err.(interface{ StatusCode() int }); ok {
+		code = serr.StatusCode()
+	}
+	page := "/500.haml"
+	if code == http.StatusNotFound {
+		page = "/404.haml"
+	}
+	if code == http.StatusNotFound || code >= 500 {
+		if engine, lerr := h.loader.Load(page); lerr == nil {
+			scope, serr := h.scope(r)
+			if serr != nil {
+				scope = make(map[string]interface{})
+			}
+			scope["status"] = code
+			if code != http.StatusNotFound {
+				scope["error"] = err
+			}
+			if output, rerr := render(engine, scope); rerr == nil {
+				w.Header().Set("Content-Type", "text/html; charset=utf-8")
+				w.WriteHeader(code)
+				w.Write(([]byte)(output))
+				return
+			}
+		}
+	}
+	if code == http.StatusNotFound {
 		http.NotFound(w, r)
-	} else {
-		w.Write(([]byte)(engine.Render(defaultScope)))
+		return
+	}
+	http.Error(w, http.StatusText(code), code)
+}
+
+// scope builds the values of the request that every page is rendered with.
+func (h *httpHamlHandler) scope(r *http.Request) (scope map[string]interface{}, err error) {
+	if err = r.ParseForm(); err != nil {
+		err = &statusError{http.StatusBadRequest, err}
+		return
+	}
+	headers := make(map[string]string)
+	for name, values := range r.Header {
+		headers[name] = values[0]
+	}
+	cookies := make(map[string]string)
+	for _, c := range r.Cookies() {
+		cookies[c.Name] = c.Value
+	}
+	scope = map[string]interface{}{
+		"request": r,
+		"path":    r.URL.Path,
+		"query":   firstValues(r.URL.Query()),
+		"form":    firstValues(r.Form),
+		"headers": headers,
+		"cookies": cookies,
+	}
+	if params, ok := r.Context().Value(paramsKey{}).(map[string]string); ok {
+		for name, value := range params {
+			scope[name] = value
+		}
+	}
+	return
+}
+
+func firstValues(values url.Values) (output map[string]string) {
+	output = make(map[string]string)
+	for name, v := range values {
+		if len(v) > 0 {
+			output[name] = v[0]
+		}
 	}
+	return
+}
+
+type statusError struct {
+	code int
+	err  error
+}
+
+func (e *statusError) Error() string {
+	return e.err.Error()
+}
+
+func (e *statusError) StatusCode() int {
+	return e.code
 }
@@ -0,0 +1,231 @@
+package gohaml
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTemplates creates a directory holding the given templates, by path.
+func writeTemplates(t *testing.T, templates map[string]string) string {
+	dir := t.TempDir()
+	for name, src := range templates {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func serve(t *testing.T, h http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestHandlerRequestScope(t *testing.T) {
+	dir := writeTemplates(t, map[string]string{
+		"page.haml": "%p= path\n%p= query.q\n%p= form.name\n%p= headers.Accept\n%p= cookies.session\n%p= request.Method",
+	})
+	h, err := NewHamlHandler(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("POST", "/page.html?q=go&q=ignored", strings.NewReader("name=Ann"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "text/html")
+	req.AddCookie(&http.Cookie{Name: "session", Value: "abc"})
+	expected := "<p>/page.html</p>\n<p>go</p>\n<p>Ann</p>\n<p>text/html</p>\n<p>abc</p>\n<p>POST</p>"
+	if output := serve(t, h, req).Body.String(); output != expected {
+		t.Errorf("expected %q\ngot      %q", expected, output)
+	}
+}
+
+type notFoundError struct{}
+
+func (notFoundError) Error() string   { return "no such user" }
+func (notFoundError) StatusCode() int { return http.StatusNotFound }
+
+func TestHandlerScopeFunc(t *testing.T) {
+	dir := writeTemplates(t, map[string]string{"user.haml": "%h1= user\n%p= path"})
+	h, err := NewHamlHandlerWithOptions(dir, HandlerOptions{Scope: func(r *http.Request) (map[string]interface{}, error) {
+		switch r.URL.Query().Get("id") {
+		case "1":
+			return map[string]interface{}{"user": "Ann", "path": "overridden"}, nil
+		case "2":
+			return nil, notFoundError{}
+		}
+		return nil, errors.New("database down")
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := serve(t, h, httptest.NewRequest("GET", "/user.html?id=1", nil))
+	if expected := "<h1>Ann</h1>\n<p>overridden</p>"; w.Code != 200 || w.Body.String() != expected {
+		t.Errorf("expected 200 %q\ngot      %d %q", expected, w.Code, w.Body.String())
+	}
+	if w = serve(t, h, httptest.NewRequest("GET", "/user.html?id=2", nil)); w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", w.Code)
+	}
+	w = serve(t, h, httptest.NewRequest("GET", "/user.html?id=3", nil))
+	if w.Code != http.StatusInternalServerError || strings.Contains(w.Body.String(), "database") {
+		t.Errorf("expected status 500 without the error text, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestHandlerRoutes(t *testing.T) {
+	dir := writeTemplates(t, map[string]string{
+		"index.haml":                   "home",
+		"about.haml":                   "about",
+		"users/index.haml":             "users",
+		"users/new.haml":               "new user",
+		"users/[id].haml":              "user #{id}\n= id",
+		"users/[id]/posts/index.haml":  "= id",
+		"users/[id]/posts/[post].haml": "= id<\n= post",
+		"docs/[...slug].haml":          "= slug",
+		"docs/intro.haml":              "intro",
+	})
+	h, err := NewHamlHandler(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	routes := []testcase{
+		testcase{"/", "home"},
+		testcase{"/about.html", "about"},
+		testcase{"/users/", "users"},
+		testcase{"/users/new.html", "new user"},
+		testcase{"/users/42.html", "user #{id}\n42"},
+		testcase{"/users/42/posts/", "42"},
+		testcase{"/users/42/posts/7.html", "427"},
+		testcase{"/docs/intro.html", "intro"},
+		testcase{"/docs/a.html", "a"},
+		testcase{"/docs/a/b/c.html", "a/b/c"},
+		testcase{"/docs/a/b/", "a/b"},
+	}
+	for i, io := range routes {
+		w := serve(t, h, httptest.NewRequest("GET", io.input, nil))
+		if w.Code != 200 || w.Body.String() != io.expected {
+			t.Errorf("(%d) Path     %q\nexpected 200 %q\ngot      %d %q", i, io.input, io.expected, w.Code, w.Body.String())
+		}
+	}
+	for _, path := range []string{"/missing.html", "/users/42/other.html", "/docs/", "/users/../about.html"} {
+		if w := serve(t, h, httptest.NewRequest("GET", path, nil)); w.Code != http.StatusNotFound {
+			t.Errorf("Path %q\nexpected status 404, got %d", path, w.Code)
+		}
+	}
+}
+
+func TestPathParam(t *testing.T) {
+	dir := writeTemplates(t, map[string]string{"[name].haml": "= greeting"})
+	h, _ := NewHamlHandlerWithOptions(dir, HandlerOptions{Scope: func(r *http.Request) (map[string]interface{}, error) {
+		return map[string]interface{}{"greeting": "Hi " + PathParam(r, "name") + PathParam(r, "other")}, nil
+	}})
+	if w := serve(t, h, httptest.NewRequest("GET", "/ann.html", nil)); w.Body.String() != "Hi ann" {
+		t.Errorf("expected %q, got %q", "Hi ann", w.Body.String())
+	}
+}
+
+type panicky struct{}
+
+func (panicky) Boom() string { panic("boom") }
+
+func TestHandlerErrorPages(t *testing.T) {
+	dir := writeTemplates(t, map[string]string{
+		"404.haml":  "%h1= status\n%p= path",
+		"500.haml":  "%h1= status\n%p= error",
+		"boom.haml": "= thing.Boom",
+		"fail.haml": "never",
+	})
+	h, _ := NewHamlHandlerWithOptions(dir, HandlerOptions{Scope: func(r *http.Request) (map[string]interface{}, error) {
+		if r.URL.Path == "/fail.html" {
+			return nil, errors.New("database down")
+		}
+		return map[string]interface{}{"thing": panicky{}}, nil
+	}})
+	cases := []struct {
+		path     string
+		code     int
+		expected string
+	}{
+		{"/nowhere.html", 404, "<h1>404</h1>\n<p>/nowhere.html</p>"},
+		{"/fail.html", 500, "<h1>500</h1>\n<p>database down</p>"},
+		{"/boom.html", 500, "<h1>500</h1>\n<p>gohaml: rendering failed: boom</p>"},
+	}
+	for _, c := range cases {
+		w := serve(t, h, httptest.NewRequest("GET", c.path, nil))
+		if w.Code != c.code || w.Body.String() != c.expected {
+			t.Errorf("Path %q\nexpected %d %q\ngot      %d %q", c.path, c.code, c.expected, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestHandlerCaching(t *testing.T) {
+	dir := writeTemplates(t, map[string]string{
+		"static.haml":  "%h1 Hello",
+		"dynamic.haml": "%h1= query.name",
+	})
+	modtime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	os.Chtimes(filepath.Join(dir, "static.haml"), modtime, modtime)
+	calls := 0
+	h, _ := NewHamlHandlerWithOptions(dir, HandlerOptions{Scope: func(r *http.Request) (map[string]interface{}, error) {
+		calls++
+		return nil, nil
+	}})
+
+	w := serve(t, h, httptest.NewRequest("GET", "/dynamic.html?name=Ann", nil))
+	tag := w.Header().Get("ETag")
+	switch {
+	case w.Header().Get("Content-Type") != "text/html; charset=utf-8":
+		t.Errorf("unexpected Content-Type %q", w.Header().Get("Content-Type"))
+	case w.Header().Get("Content-Length") != "12":
+		t.Errorf("unexpected Content-Length %q", w.Header().Get("Content-Length"))
+	case !strings.HasPrefix(tag, "\"") || len(tag) != 34:
+		t.Errorf("expected a strong ETag, got %q", tag)
+	case w.Header().Get("Last-Modified") != "":
+		t.Errorf("unexpected Last-Modified for a dynamic page %q", w.Header().Get("Last-Modified"))
+	}
+
+	req := httptest.NewRequest("GET", "/dynamic.html?name=Ann", nil)
+	req.Header.Set("If-None-Match", "\"other\", "+tag)
+	if w = serve(t, h, req); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("expected 304 without a body, got %d %q", w.Code, w.Body.String())
+	}
+	req = httptest.NewRequest("GET", "/dynamic.html?name=Bob", nil)
+	req.Header.Set("If-None-Match", tag)
+	if w = serve(t, h, req); w.Code != 200 || w.Header().Get("ETag") == tag {
+		t.Errorf("expected a new page with a new ETag, got %d %q", w.Code, w.Header().Get("ETag"))
+	}
+
+	w = serve(t, h, httptest.NewRequest("GET", "/static.html", nil))
+	if lm := w.Header().Get("Last-Modified"); lm != "Tue, 02 Jan 2024 03:04:05 GMT" {
+		t.Errorf("unexpected Last-Modified %q", lm)
+	}
+	tag = w.Header().Get("ETag")
+	req = httptest.NewRequest("GET", "/static.html", nil)
+	req.Header.Set("If-Modified-Since", "Tue, 02 Jan 2024 03:04:05 GMT")
+	if w = serve(t, h, req); w.Code != http.StatusNotModified {
+		t.Errorf("expected 304 for If-Modified-Since, got %d", w.Code)
+	}
+	req = httptest.NewRequest("GET", "/static.html", nil)
+	req.Header.Set("If-None-Match", tag)
+	req.Header.Set("If-Modified-Since", "Mon, 01 Jan 2024 00:00:00 GMT")
+	if w = serve(t, h, req); w.Code != http.StatusNotModified {
+		t.Errorf("expected If-None-Match to take precedence, got %d", w.Code)
+	}
+
+	calls = 0
+	w = serve(t, h, httptest.NewRequest("HEAD", "/dynamic.html?name=Ann", nil))
+	if w.Code != 200 || w.Body.Len() != 0 || w.Header().Get("Content-Length") != "12" || calls != 1 {
+		t.Errorf("unexpected HEAD response %d %q %q after %d scope calls", w.Code, w.Body.String(), w.Header().Get("Content-Length"), calls)
+	}
+}
@@ -1,123 +1,190 @@
+// Code generated by goyacc -o lang.go -v /dev/null lang.y. DO NOT EDIT.
+
 //line lang.y:2
 package gohaml
 
-import "fmt"
+import __yyfmt__ "fmt"
+
+//line lang.y:2
 
-var Output inode
+import "fmt"
 
-//line lang.y:9
+//line lang.y:7
 type yySymType struct {
 	yys int
 	n   inode
 	s   string
 	i   interface{}
 	c   icodenode
+	ss  []string
+	a   mixinarg
+	as  []mixinarg
+	r   *rangenode
 }
 
 const IDENT = 57346
 const ATOM = 57347
 const FOR = 57348
 const RANGE = 57349
+const DEF = 57350
+const YIELD = 57351
 
-var yyToknames = []string{
+var yyToknames = [...]string{
+	"$end",
+	"error",
+	"$unk",
 	"IDENT",
 	"ATOM",
 	"FOR",
 	"RANGE",
+	"DEF",
+	"YIELD",
+	"','",
+	"':'",
+	"'='",
+	"'('",
+	"')'",
+	"'+'",
+	"'.'",
 }
-var yyStatenames = []string{}
+
+var yyStatenames = [...]string{}
 
 const yyEofCode = 1
 const yyErrCode = 2
-const yyMaxDepth = 200
+const yyInitialStackSize = 16
 
-//line lang.y:66
+//line lang.y:178
 
 //line yacctab:1
-var yyExca = []int{
+var yyExca = [...]int8{
 	-1, 1,
 	1, -1,
 	-2, 0,
 }
 
-const yyNprod = 7
 const yyPrivate = 57344
 
-var yyTokenNames []string
-var yyStates []string
+const yyLast = 51
 
-const yyLast = 19
-
-var yyAct = []int{
-
-	13, 14, 15, 7, 12, 5, 6, 17, 3, 19,
-	2, 11, 10, 16, 8, 4, 9, 18, 1,
+var yyAct = [...]int8{
+	14, 32, 3, 22, 2, 23, 4, 6, 41, 39,
+	19, 35, 18, 5, 44, 21, 17, 12, 13, 9,
+	42, 40, 7, 49, 36, 8, 15, 16, 34, 33,
+	38, 26, 25, 47, 37, 29, 20, 45, 43, 11,
+	10, 46, 31, 30, 48, 28, 27, 24, 1, 0,
+	50,
 }
-var yyPact = []int{
 
-	4, -1000, 11, -4, -2, -7, 10, 7, -5, -1000,
-	-1000, -10, -8, -1000, 9, 0, -10, 5, -1000, -1000,
+var yyPact = [...]int16{
+	-2, -1000, 18, 8, 36, 35, -1000, 7, 22, 4,
+	-1, -3, 32, 3, -1000, -11, -1000, 27, 31, 24,
+	0, 17, -1000, 30, -1000, -1000, -11, -5, 11, -1000,
+	-6, 10, -1000, -1000, -11, 2, 22, -11, -1000, -1000,
+	29, -1000, 24, -1000, 16, -1000, -1000, -1000, -1000, 22,
+	-1000,
 }
-var yyPgo = []int{
 
-	0, 18, 16, 0,
+var yyPgo = [...]int8{
+	0, 48, 47, 3, 46, 45, 1, 43, 42, 0,
 }
-var yyR1 = []int{
 
-	0, 1, 1, 2, 2, 3, 3,
+var yyR1 = [...]int8{
+	0, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+	9, 9, 2, 2, 3, 3, 4, 4, 5, 5,
+	7, 7, 8, 8, 6, 6,
 }
-var yyR2 = []int{
 
-	0, 8, 4, 1, 2, 3, 0,
+var yyR2 = [...]int8{
+	0, 8, 6, 3, 4, 5, 2, 5, 2, 1,
+	2, 1, 1, 2, 3, 0, 1, 0, 1, 3,
+	1, 0, 1, 3, 1, 2,
 }
-var yyChk = []int{
 
-	-1000, -1, 6, 4, 4, 9, 8, 10, 4, -2,
-	5, 4, 9, -3, 11, 10, 4, 7, -3, 4,
+var yyChk = [...]int16{
+	-1000, -1, 6, 4, 8, 15, 9, 4, 7, 11,
+	4, 4, 10, 11, -9, 4, 5, 12, 13, 13,
+	4, 12, -3, 16, -2, 5, 4, -4, -5, 4,
+	-7, -8, -6, 5, 4, 11, 7, 4, -3, 14,
+	10, 14, 10, -3, 12, -9, -3, 4, -6, 7,
+	-9,
 }
-var yyDef = []int{
 
-	0, -2, 0, 0, 0, 0, 0, 0, 0, 2,
-	3, 6, 0, 4, 0, 0, 6, 0, 5, 1,
+var yyDef = [...]int8{
+	0, -2, 0, 0, 0, 0, 9, 0, 0, 0,
+	6, 8, 0, 0, 3, 15, 11, 0, 17, 21,
+	0, 0, 10, 0, 4, 12, 15, 0, 16, 18,
+	0, 20, 22, 24, 15, 0, 0, 15, 13, 5,
+	0, 7, 0, 25, 0, 2, 14, 19, 23, 0,
+	1,
 }
-var yyTok1 = []int{
 
+var yyTok1 = [...]int8{
 	1, 3, 3, 3, 3, 3, 3, 3, 3, 3,
 	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
 	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
 	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
-	3, 3, 3, 3, 8, 3, 11, 3, 3, 3,
-	3, 3, 3, 3, 3, 3, 3, 3, 9, 3,
-	3, 10,
+	13, 14, 3, 15, 10, 3, 16, 3, 3, 3,
+	3, 3, 3, 3, 3, 3, 3, 3, 11, 3,
+	3, 12,
 }
-var yyTok2 = []int{
 
-	2, 3, 4, 5, 6, 7,
+var yyTok2 = [...]int8{
+	2, 3, 4, 5, 6, 7, 8, 9,
 }
-var yyTok3 = []int{
+
+var yyTok3 = [...]int8{
 	0,
 }
 
+var yyErrorMessages = [...]struct {
+	state int
+	token int
+	msg   string
+}{}
+
 //line yaccpar:1
 
 /*	parser for yacc output	*/
 
-var yyDebug = 0
+var (
+	yyDebug        = 0
+	yyErrorVerbose = false
+)
 
 type yyLexer interface {
 	Lex(lval *yySymType) int
 	Error(s string)
 }
 
+type yyParser interface {
+	Parse(yyLexer) int
+	Lookahead() int
+}
+
+type yyParserImpl struct {
+	lval  yySymType
+	stack [yyInitialStackSize]yySymType
+	char  int
+}
+
+func (p *yyParserImpl) Lookahead() int {
+	return p.char
+}
+
+func yyNewParser() yyParser {
+	return &yyParserImpl{}
+}
+
 const yyFlag = -1000
 
 func yyTokname(c int) string {
-	if c > 0 && c <= len(yyToknames) {
+	if c >= 1 && c-1 < len(yyToknames) {
 		if yyToknames[c-1] != "" {
 			return yyToknames[c-1]
 		}
 	}
-	return fmt.Sprintf("tok-%v", c)
+	return __yyfmt__.Sprintf("tok-%v", c)
 }
 
 func yyStatname(s int) string {
@@ -126,54 +193,130 @@ func yyStatname(s int) string {
 			return yyStatenames[s]
 		}
 	}
-	return fmt.Sprintf("state-%v", s)
+	return __yyfmt__.Sprintf("state-%v", s)
 }
 
-func yylex1(lex yyLexer, lval *yySymType) int {
-	c := 0
-	char := lex.Lex(lval)
+func yyErrorMessage(state, lookAhead int) string {
+	const TOKSTART = 4
+
+	if !yyErrorVerbose {
+		return "syntax error"
+	}
+
+	for _, e := range yyErrorMessages {
+		if e.state == state && e.token == lookAhead {
+			return "syntax error: " + e.msg
+		}
+	}
+
+	res := "syntax error: unexpected " + yyTokname(lookAhead)
+
+	// To match Bison, suggest at most four expected tokens.
+	expected := make([]int, 0, 4)
+
+	// Look for shiftable tokens.
+	base := int(yyPact[state])
+	for tok := TOKSTART; tok-1 < len(yyToknames); tok++ {
+		if n := base + tok; n >= 0 && n < yyLast && int(yyChk[int(yyAct[n])]) == tok {
+			if len(expected) == cap(expected) {
+				return res
+			}
+			expected = append(expected, tok)
+		}
+	}
+
+	if yyDef[state] == -2 {
+		i := 0
+		for yyExca[i] != -1 || int(yyExca[i+1]) != state {
+			i += 2
+		}
+
+		// Look for tokens that we accept or reduce.
+		for i += 2; yyExca[i] >= 0; i += 2 {
+			tok := int(yyExca[i])
+			if tok < TOKSTART || yyExca[i+1] == 0 {
+				continue
+			}
+			if len(expected) == cap(expected) {
+				return res
+			}
+			expected = append(expected, tok)
+		}
+
+		// If the default action is to accept or reduce, give up.
+		if yyExca[i+1] != 0 {
+			return res
+		}
+	}
+
+	for i, tok := range expected {
+		if i == 0 {
+			res += ", expecting "
+		} else {
+			res += " or "
+		}
+		res += yyTokname(tok)
+	}
+	return res
+}
+
+func yylex1(lex yyLexer, lval *yySymType) (char, token int) {
+	token = 0
+	char = lex.Lex(lval)
 	if char <= 0 {
-		c = yyTok1[0]
+		token = int(yyTok1[0])
 		goto out
 	}
 	if char < len(yyTok1) {
-		c = yyTok1[char]
+		token = int(yyTok1[char])
 		goto out
 	}
 	if char >= yyPrivate {
 		if char < yyPrivate+len(yyTok2) {
-			c = yyTok2[char-yyPrivate]
+			token = int(yyTok2[char-yyPrivate])
 			goto out
 		}
 	}
 	for i := 0; i < len(yyTok3); i += 2 {
-		c = yyTok3[i+0]
-		if c == char {
-			c = yyTok3[i+1]
+		token = int(yyTok3[i+0])
+		if token == char {
+			token = int(yyTok3[i+1])
 			goto out
 		}
 	}
 
 out:
-	if c == 0 {
-		c = yyTok2[1] /* unknown char */
+	if token == 0 {
+		token = int(yyTok2[1]) /* unknown char */
 	}
 	if yyDebug >= 3 {
-		fmt.Printf("lex %U %s\n", uint(char), yyTokname(c))
+		__yyfmt__.Printf("lex %s(%d)\n", yyTokname(token), uint(char))
 	}
-	return c
+	return char, token
 }
 
 func yyParse(yylex yyLexer) int {
+	return yyNewParser().Parse(yylex)
+}
+
+func (yyrcvr *yyParserImpl) Parse(yylex yyLexer) int {
 	var yyn int
-	var yylval yySymType
 	var yyVAL yySymType
-	yyS := make([]yySymType, yyMaxDepth)
+	var yyDollar []yySymType
+	_ = yyDollar // silence set and not used
+	yyS := yyrcvr.stack[:]
 
 	Nerrs := 0   /* number of errors */
 	Errflag := 0 /* error recovery flag */
 	yystate := 0
-	yychar := -1
+	yyrcvr.char = -1
+	yytoken := -1 // yyrcvr.char translated into internal numbering
+	defer func() {
+		// Make sure we report no lookahead when not parsing.
+		yystate = -1
+		yyrcvr.char = -1
+		yytoken = -1
+	}()
 	yyp := -1
 	goto yystack
 
@@ -186,7 +329,7 @@ ret1:
 yystack:
 	/* put a state and value onto the stack */
 	if yyDebug >= 4 {
-		fmt.Printf("char %v in %v\n", yyTokname(yychar), yyStatname(yystate))
+		__yyfmt__.Printf("char %v in %v\n", yyTokname(yytoken), yyStatname(yystate))
 	}
 
 	yyp++
@@ -199,21 +342,22 @@ yystack:
 	yyS[yyp].yys = yystate
 
 yynewstate:
-	yyn = yyPact[yystate]
+	yyn = int(yyPact[yystate])
 	if yyn <= yyFlag {
 		goto yydefault /* simple state */
 	}
-	if yychar < 0 {
-		yychar = yylex1(yylex, &yylval)
+	if yyrcvr.char < 0 {
+		yyrcvr.char, yytoken = yylex1(yylex, &yyrcvr.lval)
 	}
-	yyn += yychar
+	yyn += yytoken
 	if yyn < 0 || yyn >= yyLast {
 		goto yydefault
 	}
-	yyn = yyAct[yyn]
-	if yyChk[yyn] == yychar { /* valid shift */
-		yychar = -1
-		yyVAL = yylval
+	yyn = int(yyAct[yyn])
+	if int(yyChk[yyn]) == yytoken { /* valid shift */
+		yyrcvr.char = -1
+		yytoken = -1
+		yyVAL = yyrcvr.lval
 		yystate = yyn
 		if Errflag > 0 {
 			Errflag--
@@ -223,27 +367,27 @@ yynewstate:
 
 yydefault:
 	/* default state action */
-	yyn = yyDef[yystate]
+	yyn = int(yyDef[yystate])
 	if yyn == -2 {
-		if yychar < 0 {
-			yychar = yylex1(yylex, &yylval)
+		if yyrcvr.char < 0 {
+			yyrcvr.char, yytoken = yylex1(yylex, &yyrcvr.lval)
 		}
 
 		/* look through exception table */
 		xi := 0
 		for {
-			if yyExca[xi+0] == -1 && yyExca[xi+1] == yystate {
+			if yyExca[xi+0] == -1 && int(yyExca[xi+1]) == yystate {
 				break
 			}
 			xi += 2
 		}
 		for xi += 2; ; xi += 2 {
-			yyn = yyExca[xi+0]
-			if yyn < 0 || yyn == yychar {
+			yyn = int(yyExca[xi+0])
+			if yyn < 0 || yyn == yytoken {
 				break
 			}
 		}
-		yyn = yyExca[xi+1]
+		yyn = int(yyExca[xi+1])
 		if yyn < 0 {
 			goto ret0
 		}
@@ -252,11 +396,11 @@ yydefault:
 		/* error ... attempt to resume parsing */
 		switch Errflag {
 		case 0: /* brand new error */
-			yylex.Error("syntax error")
+			yylex.Error(yyErrorMessage(yystate, yytoken))
 			Nerrs++
 			if yyDebug >= 1 {
-				fmt.Printf("%s", yyStatname(yystate))
-				fmt.Printf("saw %s\n", yyTokname(yychar))
+				__yyfmt__.Printf("%s", yyStatname(yystate))
+				__yyfmt__.Printf(" saw %s\n", yyTokname(yytoken))
 			}
 			fallthrough
 
@@ -265,17 +409,17 @@ yydefault:
 
 			/* find a state where "error" is a legal shift action */
 			for yyp >= 0 {
-				yyn = yyPact[yyS[yyp].yys] + yyErrCode
+				yyn = int(yyPact[yyS[yyp].yys]) + yyErrCode
 				if yyn >= 0 && yyn < yyLast {
-					yystate = yyAct[yyn] /* simulate a shift of "error" */
-					if yyChk[yystate] == yyErrCode {
+					yystate = int(yyAct[yyn]) /* simulate a shift of "error" */
+					if int(yyChk[yystate]) == yyErrCode {
 						goto yystack
 					}
 				}
 
 				/* the current p has no shift on "error", pop stack */
 				if yyDebug >= 2 {
-					fmt.Printf("error recovery pops state %d\n", yyS[yyp].yys)
+					__yyfmt__.Printf("error recovery pops state %d\n", yyS[yyp].yys)
 				}
 				yyp--
 			}
@@ -284,86 +428,232 @@ yydefault:
 
 		case 3: /* no shift yet; clobber input char */
 			if yyDebug >= 2 {
-				fmt.Printf("error recovery discards %s\n", yyTokname(yychar))
+				__yyfmt__.Printf("error recovery discards %s\n", yyTokname(yytoken))
 			}
-			if yychar == yyEofCode {
+			if yytoken == yyEofCode {
 				goto ret1
 			}
-			yychar = -1
+			yyrcvr.char = -1
+			yytoken = -1
 			goto yynewstate /* try again in the same state */
 		}
 	}
 
 	/* reduction by production yyn */
 	if yyDebug >= 2 {
-		fmt.Printf("reduce %v in:\n\t%v\n", yyn, yyStatname(yystate))
+		__yyfmt__.Printf("reduce %v in:\n\t%v\n", yyn, yyStatname(yystate))
 	}
 
 	yynt := yyn
 	yypt := yyp
 	_ = yypt // guard against "declared and not used"
 
-	yyp -= yyR2[yyn]
+	yyp -= int(yyR2[yyn])
+	// yyp is now the index of $0. Perform the default action. Iff the
+	// reduced production is ε, $1 is possibly out of range.
+	if yyp+1 >= len(yyS) {
+		nyys := make([]yySymType, len(yyS)*2)
+		copy(nyys, yyS)
+		yyS = nyys
+	}
 	yyVAL = yyS[yyp+1]
 
 	/* consult goto table to find next state */
-	yyn = yyR1[yyn]
-	yyg := yyPgo[yyn]
+	yyn = int(yyR1[yyn])
+	yyg := int(yyPgo[yyn])
 	yyj := yyg + yyS[yyp].yys + 1
 
 	if yyj >= yyLast {
-		yystate = yyAct[yyg]
+		yystate = int(yyAct[yyg])
 	} else {
-		yystate = yyAct[yyj]
-		if yyChk[yystate] != -yyn {
-			yystate = yyAct[yyg]
+		yystate = int(yyAct[yyj])
+		if int(yyChk[yystate]) != -yyn {
+			yystate = int(yyAct[yyg])
 		}
 	}
 	// dummy call; replaced with literal code
 	switch yynt {
 
 	case 1:
-		//line lang.y:25
+		yyDollar = yyS[yypt-8 : yypt+1]
+//line lang.y:31
 		{
-			rn := new(rangenode)
-			rn._lhs1 = yyS[yypt-6].s
-			rn._lhs2 = yyS[yypt-4].s
-			rn._rhs = res{yyS[yypt-0].s, true}
-			yyVAL.n = rn
-			Output = yyVAL.n
+			yyDollar[8].r._lhs1 = yyDollar[2].s
+			yyDollar[8].r._lhs2 = yyDollar[4].s
+			yyVAL.n = yyDollar[8].r
+			yylex.(*Lexer).output = yyVAL.n
 		}
 	case 2:
-		//line lang.y:34
+		yyDollar = yyS[yypt-6 : yypt+1]
+//line lang.y:38
 		{
-			yyS[yypt-0].c.setLHS(yyS[yypt-3].s)
-			yyVAL.n = yyS[yypt-0].c
-			Output = yyVAL.n
+			yyDollar[6].r._lhs1 = yyDollar[2].s
+			yyVAL.n = yyDollar[6].r
+			yylex.(*Lexer).output = yyVAL.n
 		}
 	case 3:
-		//line lang.y:42
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line lang.y:44
+		{
+			yyVAL.n = yyDollar[3].r
+			yylex.(*Lexer).output = yyVAL.n
+		}
+	case 4:
+		yyDollar = yyS[yypt-4 : yypt+1]
+//line lang.y:49
+		{
+			yyDollar[4].c.setLHS(yyDollar[1].s)
+			yyVAL.n = yyDollar[4].c
+			yylex.(*Lexer).output = yyVAL.n
+		}
+	case 5:
+		yyDollar = yyS[yypt-5 : yypt+1]
+//line lang.y:55
+		{
+			dn := new(defnode)
+			dn._name = yyDollar[2].s
+			dn._params = yyDollar[4].ss
+			yyVAL.n = dn
+			yylex.(*Lexer).output = yyVAL.n
+		}
+	case 6:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line lang.y:63
+		{
+			dn := new(defnode)
+			dn._name = yyDollar[2].s
+			yyVAL.n = dn
+			yylex.(*Lexer).output = yyVAL.n
+		}
+	case 7:
+		yyDollar = yyS[yypt-5 : yypt+1]
+//line lang.y:70
+		{
+			cn := new(callnode)
+			cn._name = yyDollar[2].s
+			cn._args = yyDollar[4].as
+			yyVAL.n = cn
+			yylex.(*Lexer).output = yyVAL.n
+		}
+	case 8:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line lang.y:78
+		{
+			cn := new(callnode)
+			cn._name = yyDollar[2].s
+			yyVAL.n = cn
+			yylex.(*Lexer).output = yyVAL.n
+		}
+	case 9:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line lang.y:85
+		{
+			yyVAL.n = new(yieldnode)
+			yylex.(*Lexer).output = yyVAL.n
+		}
+	case 10:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line lang.y:92
+		{
+			yyVAL.r = new(rangenode)
+			yyVAL.r._rhs = res{yyDollar[1].s + yyDollar[2].s, true}
+		}
+	case 11:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line lang.y:97
+		{
+			yyVAL.r = new(rangenode)
+			yyVAL.r._count = yyDollar[1].i
+		}
+	case 12:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line lang.y:104
 		{
 			dan := new(declassnode)
-			dan._rhs = yyS[yypt-0].i
+			dan._rhs = yyDollar[1].i
 			yyVAL.c = dan
 		}
-	case 4:
-		//line lang.y:48
+	case 13:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line lang.y:110
 		{
 			dan := new(vdeclassnode)
-			dan._rhs.value = yyS[yypt-1].s + yyS[yypt-0].s
+			dan._rhs.value = yyDollar[1].s + yyDollar[2].s
 			dan._rhs.needsResolution = true
 			yyVAL.c = dan
 		}
-	case 5:
-		//line lang.y:57
+	case 14:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line lang.y:119
 		{
-			yyVAL.s = fmt.Sprintf(".%s%s", yyS[yypt-1].s, yyS[yypt-0].s)
+			yyVAL.s = fmt.Sprintf(".%s%s", yyDollar[2].s, yyDollar[3].s)
 		}
-	case 6:
-		//line lang.y:61
+	case 15:
+		yyDollar = yyS[yypt-0 : yypt+1]
+//line lang.y:123
 		{
 			yyVAL.s = ""
 		}
+	case 16:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line lang.y:129
+		{
+			yyVAL.ss = yyDollar[1].ss
+		}
+	case 17:
+		yyDollar = yyS[yypt-0 : yypt+1]
+//line lang.y:133
+		{
+			yyVAL.ss = nil
+		}
+	case 18:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line lang.y:139
+		{
+			yyVAL.ss = []string{yyDollar[1].s}
+		}
+	case 19:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line lang.y:143
+		{
+			yyVAL.ss = append(yyDollar[1].ss, yyDollar[3].s)
+		}
+	case 20:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line lang.y:149
+		{
+			yyVAL.as = yyDollar[1].as
+		}
+	case 21:
+		yyDollar = yyS[yypt-0 : yypt+1]
+//line lang.y:153
+		{
+			yyVAL.as = nil
+		}
+	case 22:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line lang.y:159
+		{
+			yyVAL.as = []mixinarg{yyDollar[1].a}
+		}
+	case 23:
+		yyDollar = yyS[yypt-3 : yypt+1]
+//line lang.y:163
+		{
+			yyVAL.as = append(yyDollar[1].as, yyDollar[3].a)
+		}
+	case 24:
+		yyDollar = yyS[yypt-1 : yypt+1]
+//line lang.y:169
+		{
+			yyVAL.a = mixinarg{yyDollar[1].i, res{}}
+		}
+	case 25:
+		yyDollar = yyS[yypt-2 : yypt+1]
+//line lang.y:173
+		{
+			yyVAL.a = mixinarg{nil, res{yyDollar[1].s + yyDollar[2].s, true}}
+		}
 	}
 	goto yystack /* stack new state and value */
 }
@@ -2,8 +2,6 @@
 package gohaml
 
 import "fmt"
-
-var Output inode
 %}
 
 %union {
@@ -11,33 +9,97 @@ var Output inode
   s string
   i interface{}
   c icodenode
+  ss []string
+  a mixinarg
+  as []mixinarg
+  r *rangenode
 }
 
 %type<n> statement
 %type<c> rhs
 %type<s> complex_ident
+%type<ss> params param_list
+%type<a> arg
+%type<as> args arg_list
+%type<r> range_target
 %token<s> IDENT
-%token<i> ATOM FOR RANGE
+%token<i> ATOM FOR RANGE DEF YIELD
 
 %%
 
-statement :  FOR IDENT ',' IDENT ':' '=' RANGE IDENT
+statement :  FOR IDENT ',' IDENT ':' '=' RANGE range_target
+            {
+              $8._lhs1 = $2
+              $8._lhs2 = $4
+              $$ = $8
+              yylex.(*Lexer).output = $$
+            }
+          | FOR IDENT ':' '=' RANGE range_target
             {
-              rn := new(rangenode)
-              rn._lhs1 = $2
-              rn._lhs2 = $4
-              rn._rhs = res{$8, true}
-              $$ = rn
-              Output = $$
+              $6._lhs1 = $2
+              $$ = $6
+              yylex.(*Lexer).output = $$
+            }
+          | FOR RANGE range_target
+            {
+              $$ = $3
+              yylex.(*Lexer).output = $$
             }
           | IDENT ':' '=' rhs
             {
               $4.setLHS($1)
               $$ = $4
-              Output = $$
+              yylex.(*Lexer).output = $$
+            }
+          | DEF IDENT '(' params ')'
+            {
+              dn := new(defnode)
+              dn._name = $2
+              dn._params = $4
+              $$ = dn
+              yylex.(*Lexer).output = $$
+            }
+          | DEF IDENT
+            {
+              dn := new(defnode)
+              dn._name = $2
+              $$ = dn
+              yylex.(*Lexer).output = $$
+            }
+          | '+' IDENT '(' args ')'
+            {
+              cn := new(callnode)
+              cn._name = $2
+              cn._args = $4
+              $$ = cn
+              yylex.(*Lexer).output = $$
+            }
+          | '+' IDENT
+            {
+              cn := new(callnode)
+              cn._name = $2
+              $$ = cn
+              yylex.(*Lexer).output = $$
+            }
+          | YIELD
+            {
+              $$ = new(yieldnode)
+              yylex.(*Lexer).output = $$
             }
           ;
 
+range_target : IDENT complex_ident
+               {
+                 $$ = new(rangenode)
+                 $$._rhs = res{$1 + $2, true}
+               }
+             | ATOM
+               {
+                 $$ = new(rangenode)
+                 $$._count = $1
+               }
+             ;
+
 rhs : ATOM
       {
         dan := new(declassnode)
@@ -63,4 +125,54 @@ complex_ident : '.' IDENT complex_ident
                 }
               ;
 
+params : param_list
+         {
+           $$ = $1
+         }
+       |
+         {
+           $$ = nil
+         }
+       ;
+
+param_list : IDENT
+             {
+               $$ = []string{$1}
+             }
+           | param_list ',' IDENT
+             {
+               $$ = append($1, $3)
+             }
+           ;
+
+args : arg_list
+       {
+         $$ = $1
+       }
+     |
+       {
+         $$ = nil
+       }
+     ;
+
+arg_list : arg
+           {
+             $$ = []mixinarg{$1}
+           }
+         | arg_list ',' arg
+           {
+             $$ = append($1, $3)
+           }
+         ;
+
+arg : ATOM
+      {
+        $$ = mixinarg{$1, res{}}
+      }
+    | IDENT complex_ident
+      {
+        $$ = mixinarg{nil, res{$1 + $2, true}}
+      }
+    ;
+
 %%
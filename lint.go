@@ -0,0 +1,251 @@
+package gohaml
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Severity tells how serious a LintIssue is.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Rule identifiers reported by Lint.
+const (
+	RuleSyntax               = "syntax"
+	RuleMixedIndentation     = "mixed-indentation"
+	RuleUnusedAssignment     = "unused-assignment"
+	RuleShadowedRangeVar     = "shadowed-range-var"
+	RuleIncludeMissing       = "include-missing"
+	RuleDuplicateID          = "duplicate-id"
+	RuleIneffectiveNoNewline = "ineffective-nonewline"
+)
+
+// LintIssue is a problem found by Lint. Line and Column are 1-based.
+type LintIssue struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Line     int      `json:"line"`
+	Column   int      `json:"column"`
+	Msg      string   `json:"message"`
+}
+
+func (self LintIssue) String() string {
+	return fmt.Sprintf("%d:%d: %s: %s (%s)", self.Line, self.Column, self.Severity, self.Msg, self.Rule)
+}
+
+// Lint reports the likely mistakes in a template, sorted by position. The
+// targets of %include are looked up through the loader, unless it is nil.
+// A template that does not parse yields a single syntax issue.
+func Lint(input string, loader Loader) (issues []LintIssue) {
+	// the parser refuses mixed indentation outright; report it and lint the
+	// rest with every indentation character read as a space.
+	var mixed []LintIssue
+	input, mixed = normalizeIndentation(input)
+
+	t, err := parser.parse(input)
+	if err != nil {
+		line := 0
+		if serr, ok := err.(*SyntaxError); ok {
+			line = serr.Line
+		}
+		return append(mixed, LintIssue{RuleSyntax, SeverityError, line, 1, strings.TrimSpace(err.Error())})
+	}
+	return lintTree(t, loader, mixed)
+}
+
+// Lint reports the likely mistakes in the parsed template of the engine,
+// including the changes made by Transform, sorted by position. Unlike the
+// Lint function it cannot report mixed indentation, which the parser
+// refuses before an engine exists.
+func (self *Engine) Lint(loader Loader) (issues []LintIssue) {
+	return lintTree(self.ast, loader, nil)
+}
+
+func lintTree(t *tree, loader Loader, issues []LintIssue) []LintIssue {
+	l := &linter{tree: t, loader: loader, issues: issues, ids: make(map[string]int), used: make(map[string]bool)}
+	l.references(t.nodes)
+	l.nodes(t.nodes, nil, nil)
+	sort.SliceStable(l.issues, func(i, j int) bool {
+		a, b := l.issues[i], l.issues[j]
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+	return l.issues
+}
+
+func normalizeIndentation(input string) (output string, issues []LintIssue) {
+	lines := strings.Split(input, "\n")
+	var first rune
+	for i, line := range lines {
+		indent := len(line) - len(strings.TrimLeftFunc(line, unicode.IsSpace))
+		if indent == len(line) {
+			continue
+		}
+		for _, r := range line[:indent] {
+			if first == 0 {
+				first = r
+			} else if r != first {
+				issues = append(issues, LintIssue{RuleMixedIndentation, SeverityWarning, i + 1, 1, "indentation mixes tabs and spaces"})
+				break
+			}
+		}
+		lines[i] = strings.Repeat(" ", indent) + line[indent:]
+	}
+	output = strings.Join(lines, "\n")
+	return
+}
+
+type linter struct {
+	tree   *tree
+	loader Loader
+	issues []LintIssue
+	ids    map[string]int
+	used   map[string]bool
+}
+
+func (self *linter) report(n inode, rule string, severity Severity, format string, args ...interface{}) {
+	self.issues = append(self.issues, LintIssue{rule, severity, self.tree.lines[n], n.indentLevel() + 1, fmt.Sprintf(format, args...)})
+}
+
+// references records the first element of every path looked up in scope.
+func (self *linter) references(nodes []inode) {
+	use := func(r res) {
+		if r.needsResolution {
+			self.used[strings.SplitN(r.value, ".", 2)[0]] = true
+		}
+	}
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case *node:
+			use(n._remainder)
+			for _, attr := range n._attrs {
+				use(attr.key)
+				use(attr.value)
+			}
+		case *rangenode:
+			use(n._rhs)
+		case *vdeclassnode:
+			use(n._rhs)
+		case *callnode:
+			for _, arg := range n._args {
+				use(arg._path)
+			}
+		case *commentnode:
+			continue
+		}
+		self.references(childrenOf(n))
+	}
+}
+
+// nodes checks a list of siblings. names maps the range variables and
+// mixin parameters in effect to a description of where they come from, and
+// loop is the innermost enclosing range.
+func (self *linter) nodes(nodes []inode, names map[string]string, loop *rangenode) {
+	siblings := rendered(nodes)
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case *node:
+			self.node(n, siblings, loop)
+		case *declassnode:
+			self.assignment(n, n._lhs)
+		case *vdeclassnode:
+			self.assignment(n, n._lhs)
+		case *rangenode:
+			inner := make(map[string]string)
+			for name, from := range names {
+				inner[name] = from
+			}
+			for _, name := range []string{n._lhs1, n._lhs2} {
+				if name == "" || name == "_" {
+					continue
+				}
+				if from, ok := inner[name]; ok {
+					self.report(n, RuleShadowedRangeVar, SeverityWarning, "range variable %s shadows %s", name, from)
+				}
+				inner[name] = fmt.Sprintf("the range variable on line %d", self.tree.lines[n])
+			}
+			self.nodes(n._children, inner, n)
+			continue
+		case *defnode:
+			inner := make(map[string]string)
+			for _, param := range n._params {
+				inner[param] = fmt.Sprintf("the parameter of mixin %s", n._name)
+			}
+			self.nodes(n._children, inner, nil)
+			continue
+		case *commentnode:
+			continue
+		}
+		self.nodes(childrenOf(n), names, loop)
+	}
+}
+
+func (self *linter) assignment(n inode, name string) {
+	if !self.used[name] {
+		self.report(n, RuleUnusedAssignment, SeverityWarning, "%s is assigned but never used", name)
+	}
+}
+
+func (self *linter) node(n *node, siblings []inode, loop *rangenode) {
+	if n._name == "include" && !n._remainder.needsResolution && self.loader != nil {
+		target := t(n._remainder.value)
+		if _, err := self.loader.Load(target); os.IsNotExist(err) {
+			self.report(n, RuleIncludeMissing, SeverityError, "included template %q does not exist", target)
+		} else if err != nil {
+			self.report(n, RuleIncludeMissing, SeverityError, "cannot load included template %q: %s", target, err)
+		}
+	}
+
+	var ids []string
+	static := true
+	for _, attr := range n._attrs {
+		if attr.key.needsResolution || attr.key.value != "id" {
+			continue
+		}
+		static = static && !attr.value.needsResolution
+		ids = append(ids, attr.value.value)
+	}
+	if len(ids) > 0 && static {
+		id := strings.Join(ids, " ")
+		if loop != nil {
+			self.report(n, RuleDuplicateID, SeverityWarning, "id %q is repeated on every iteration of the loop on line %d", id, self.tree.lines[loop])
+		} else if line, ok := self.ids[id]; ok {
+			self.report(n, RuleDuplicateID, SeverityWarning, "duplicate id %q, first used on line %d", id, line)
+		} else {
+			self.ids[id] = self.tree.lines[n]
+		}
+	}
+
+	// "<" joins a tag with its children, and a line with the next one
+	// where siblings are not written by an enclosing tag.
+	if n._noNewline && len(rendered(n._children)) == 0 {
+		_, inTag := n.parent().(*node)
+		if inTag || len(siblings) > 0 && siblings[len(siblings)-1] == inode(n) {
+			self.report(n, RuleIneffectiveNoNewline, SeverityWarning, "< has no effect here")
+		}
+	}
+}
+
+func childrenOf(n inode) []inode {
+	switch n := n.(type) {
+	case *node:
+		return n._children
+	case *rangenode:
+		return n._children
+	case *declassnode:
+		return n._children
+	case *vdeclassnode:
+		return n._children
+	case *defnode:
+		return n._children
+	case *callnode:
+		return n._children
+	}
+	return nil
+}
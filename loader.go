@@ -5,7 +5,11 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 )
 
 // Loader, Entry are not particularly nice and custom tailored to the http handlers
@@ -18,10 +22,30 @@ type Loader interface {
 }
 
 type fileSystemLoader struct {
-	baseDir string
+	baseDir      string
+	mixins       *mixinRegistry
+	transformers []Transformer
+
+	sync.Mutex
+	partials map[string]*partial
+	version  time.Time
+}
+
+// partial is a template whose name starts with an underscore. The mixins
+// it defines can be called from every template of its loader.
+type partial struct {
+	modTime time.Time
+	mixins  map[string]*defnode
 }
 
-func NewFileSystemLoader(dir string) (loader Loader, err error) {
+// NewFileSystemLoader returns a Loader for the templates below dir. The
+// transformers are run on every template it loads.
+//
+// The mixins defined by partials, the templates below dir whose names start
+// with an underscore, can be called from every template of the loader, and
+// the ones defined by other templates only from the template itself. The
+// partials are read again when they change.
+func NewFileSystemLoader(dir string, transformers ...Transformer) (loader Loader, err error) {
 	var f *os.File
 	if f, err = os.Open(dir); err != nil {
 		return
@@ -42,7 +66,7 @@ func NewFileSystemLoader(dir string) (loader Loader, err error) {
 		dir += "/"
 	}
 
-	return &fileSystemLoader{dir}, nil
+	return &fileSystemLoader{baseDir: dir, mixins: newMixinRegistry(), transformers: transformers, partials: make(map[string]*partial)}, nil
 }
 
 func (l *fileSystemLoader) Load(id_string interface{}) (engine *Engine, err error) {
@@ -53,9 +77,19 @@ func (l *fileSystemLoader) Load(id_string interface{}) (engine *Engine, err erro
 		return
 	}
 
+	if _, err = l.loadPartials(); err != nil {
+		return
+	}
+	if engine, err = l.parse(l.baseDir + id); err != nil {
+		return
+	}
+	engine.shared = l.mixins
+	return
+}
+
+// parse reads the template at path and runs the transformers on it.
+func (l *fileSystemLoader) parse(path string) (engine *Engine, err error) {
 	var file *os.File
-	// check fs
-	var path = l.baseDir + id
 	if file, err = os.Open(path); err != nil {
 		return
 	}
@@ -67,5 +101,96 @@ func (l *fileSystemLoader) Load(id_string interface{}) (engine *Engine, err erro
 		return
 	}
 
-	return NewEngine(bb.String())
+	if engine, err = NewEngine(bb.String()); err != nil {
+		return
+	}
+	if len(l.transformers) > 0 {
+		if err = engine.Transform(l.transformers...); err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
+// loadPartials reads the partials that are new or changed since the last
+// call, makes their mixins callable, and returns the time the set of
+// partials last changed.
+func (l *fileSystemLoader) loadPartials() (version time.Time, err error) {
+	l.Lock()
+	defer l.Unlock()
+	seen := make(map[string]bool)
+	changed := false
+	err = filepath.Walk(l.baseDir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() || !isPartial(fi.Name()) {
+			return nil
+		}
+		seen[path] = true
+		if p, ok := l.partials[path]; ok && p.modTime.Equal(fi.ModTime()) {
+			return nil
+		}
+		engine, err := l.parse(path)
+		if err != nil {
+			rel, _ := filepath.Rel(l.baseDir, path)
+			return templateError("load", rel, err)
+		}
+		l.partials[path] = &partial{fi.ModTime(), engine.mixins}
+		if fi.ModTime().After(l.version) {
+			l.version = fi.ModTime()
+		}
+		changed = true
+		return nil
+	})
+	if err != nil {
+		return
+	}
+	for path := range l.partials {
+		if !seen[path] {
+			// a partial that is gone may not be the newest one.
+			delete(l.partials, path)
+			l.version = time.Now()
+			changed = true
+		}
+	}
+	if changed {
+		paths := make([]string, 0, len(l.partials))
+		for path := range l.partials {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		defs := make(map[string]*defnode)
+		for _, path := range paths {
+			for name, def := range l.partials[path].mixins {
+				defs[name] = def
+			}
+		}
+		l.mixins.set(defs)
+	}
+	return l.version, nil
+}
+
+// modTime returns the time the markup of the template id last changed: its
+// own modification time, or the last change to the partials, whichever is
+// later.
+func (l *fileSystemLoader) modTime(id string) (version time.Time, err error) {
+	var fi os.FileInfo
+	if fi, err = os.Stat(l.baseDir + id); err != nil {
+		return
+	}
+	var partials time.Time
+	if partials, err = l.loadPartials(); err != nil {
+		return
+	}
+	version = fi.ModTime()
+	if partials.After(version) {
+		version = partials
+	}
+	return
+}
+
+// isPartial tells whether a file is a partial.
+func isPartial(name string) bool {
+	return strings.HasPrefix(name, "_") && filepath.Ext(name) == ".haml"
 }
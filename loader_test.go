@@ -5,7 +5,9 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 const test_dir = "./test"
@@ -44,6 +46,65 @@ func TestLoadFile(t *testing.T) {
 	}
 }
 
+func TestLoadSharesMixins(t *testing.T) {
+	fsl, err := NewFileSystemLoader(test_dir)
+	if err != nil {
+		t.Fatalf("couldn't create fileSystemLoader: %s", err)
+	}
+	engine, err := fsl.Load("mixin_page.haml")
+	if err != nil {
+		t.Fatalf("couldn't load: mixin_page.haml: %s", err)
+	}
+	expected := "<div>\n\t<p>Bla</p>\n</div>"
+	if output := engine.Render(map[string]interface{}{}); output != expected {
+		t.Errorf("expected %q, got %q", expected, output)
+	}
+}
+
+func TestLoadPartials(t *testing.T) {
+	dir := writeTemplates(t, map[string]string{
+		"_cards.haml": "- def card\n  %p card",
+		"page.haml":   "- def own\n  %p own\n+card\n+own",
+		"other.haml":  "+own",
+	})
+	fsl, err := NewFileSystemLoader(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	render := func(name string) (string, error) {
+		engine, err := fsl.Load(name)
+		if err != nil {
+			t.Fatalf("couldn't load: %s: %s", name, err)
+		}
+		return engine.RenderValue(nil)
+	}
+	if output, err := render("page.haml"); err != nil || output != "<p>card</p>\n<p>own</p>" {
+		t.Errorf("unexpected output %q %v", output, err)
+	}
+	// mixins of pages are only callable from the page itself.
+	if _, err := render("other.haml"); err == nil {
+		t.Errorf("expected an error for a mixin of another page")
+	}
+
+	later := time.Now().Add(time.Hour)
+	path := filepath.Join(dir, "_cards.haml")
+	os.WriteFile(path, []byte("- def card\n  %p new card"), 0644)
+	os.Chtimes(path, later, later)
+	if output, _ := render("page.haml"); output != "<p>new card</p>\n<p>own</p>" {
+		t.Errorf("expected the changed partial to be read again, got %q", output)
+	}
+
+	os.Remove(path)
+	if _, err := render("page.haml"); err == nil {
+		t.Errorf("expected an error for the mixin of a removed partial")
+	}
+
+	os.WriteFile(path, []byte("- def card(\n"), 0644)
+	if _, err := fsl.Load("page.haml"); err == nil {
+		t.Errorf("expected an error for a partial that does not parse")
+	}
+}
+
 func readFile(t *testing.T, fn string) ([]byte, error) {
 	file, err := os.Open(fn)
 	if err != nil {
@@ -0,0 +1,123 @@
+package gohaml
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+/*
+FieldLookup selects how the names of a path are matched to the fields and methods of
+structs. The zero value matches them exactly, by their Go names. The options combine:
+
+	engine.FieldLookup = gohaml.LookupTags | gohaml.LookupFoldCase
+
+Whatever the lookup, fields tagged `haml:"-"`, and fields tagged `json:"-"` that have
+no haml tag, are hidden from templates.
+*/
+type FieldLookup int
+
+const (
+	// LookupTags matches a field by the name in its haml tag, or in its json
+	// tag when it has no haml tag, rather than by its Go name.
+	LookupTags FieldLookup = 1 << iota
+
+	// LookupFoldCase also matches names whose first letter differs in case,
+	// so that user.name finds the field or method Name.
+	LookupFoldCase
+)
+
+// namedField is an exported field of a struct with the name it is looked up
+// by.
+type namedField struct {
+	name  string
+	index []int
+	typ   reflect.Type
+}
+
+type namedFieldsKey struct {
+	t    reflect.Type
+	tags bool
+}
+
+// namedFieldsCache holds the []namedField of struct types.
+var namedFieldsCache sync.Map
+
+// namedFields returns the exported fields of a struct type, including the
+// promoted ones, named by their tags when tags is set. Fields tagged
+// `haml:"-"`, or `json:"-"` without a haml tag, are left out.
+func namedFields(t reflect.Type, tags bool) []namedField {
+	key := namedFieldsKey{t, tags}
+	if fields, ok := namedFieldsCache.Load(key); ok {
+		return fields.([]namedField)
+	}
+	var fields []namedField
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() {
+			continue
+		}
+		name := f.Name
+		switch tag := fieldTag(f); {
+		case tag == "-":
+			continue
+		case tags && tag != "":
+			name = tag
+		}
+		fields = append(fields, namedField{name, f.Index, f.Type})
+	}
+	namedFieldsCache.Store(key, fields)
+	return fields
+}
+
+// fieldTag returns the name in the haml tag of a field, or in its json tag
+// when it has no haml tag.
+func fieldTag(f reflect.StructField) string {
+	tag, ok := f.Tag.Lookup("haml")
+	if !ok {
+		tag = f.Tag.Get("json")
+	}
+	tag, _, _ = strings.Cut(tag, ",")
+	return tag
+}
+
+// field returns the field of the struct type t that key names.
+func (self FieldLookup) field(t reflect.Type, key string) (index []int, typ reflect.Type, ok bool) {
+	if self == 0 {
+		// unlike the other lookups, the exact one also finds unexported
+		// fields.
+		f, found := t.FieldByName(key)
+		if !found || fieldTag(f) == "-" {
+			return
+		}
+		return f.Index, f.Type, true
+	}
+	for _, f := range namedFields(t, self&LookupTags != 0) {
+		if f.name == key || self&LookupFoldCase != 0 && equalFoldFirst(f.name, key) {
+			return f.index, f.typ, true
+		}
+	}
+	return
+}
+
+// method returns the name of the method of t that key names, if t has one.
+func (self FieldLookup) method(t reflect.Type, key string) string {
+	if _, ok := t.MethodByName(key); ok || self&LookupFoldCase == 0 {
+		return key
+	}
+	return upperFirst(key)
+}
+
+// equalFoldFirst tells whether two names are equal but for the case of
+// their first letter.
+func equalFoldFirst(a string, b string) bool {
+	ra, na := utf8.DecodeRuneInString(a)
+	rb, nb := utf8.DecodeRuneInString(b)
+	return a[na:] == b[nb:] && unicode.ToLower(ra) == unicode.ToLower(rb)
+}
+
+func upperFirst(s string) string {
+	r, n := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(r)) + s[n:]
+}
@@ -0,0 +1,54 @@
+package gohaml
+
+// Loop describes the current iteration of a range. Templates reach it as
+// loop inside the body of the range, which hides a scope value of that name
+// for the duration of the loop:
+//
+//	%table
+//	  - for _, row := range rows
+//	    %tr{:class => loop.Parity}
+//	      %td= loop.Number
+//
+// Length is -1 in ranges over channels and functions, which are consumed as
+// the loop runs. Parent is the Loop of the enclosing range, or nil.
+type Loop struct {
+	Index  int
+	Number int
+	Length int
+	First  bool
+	Last   bool
+	Odd    bool
+	Even   bool
+	Parent *Loop
+}
+
+func newLoop(index int, length int, parent *Loop) *Loop {
+	return &Loop{
+		Index:  index,
+		Number: index + 1,
+		Length: length,
+		First:  index == 0,
+		Last:   index == length-1,
+		Odd:    index%2 == 1,
+		Even:   index%2 == 0,
+		Parent: parent,
+	}
+}
+
+// Parity returns "even" for the first, third, ... iteration and "odd" for
+// the others, following Even and Odd.
+func (self *Loop) Parity() string {
+	if self.Odd {
+		return "odd"
+	}
+	return "even"
+}
+
+// Comma returns ", " for every iteration but the last, to write lists such
+// as "a, b, c" when used as the last line of the body with <.
+func (self *Loop) Comma() string {
+	if self.Last {
+		return ""
+	}
+	return ", "
+}
@@ -1,18 +1,27 @@
 package gohaml
 
 import (
-	"errors"
 	"fmt"
-	"os"
 	"strconv"
 	"strings"
 	"text/scanner"
 	"unicode"
+	"unicode/utf8"
 )
 
 type hamlParser struct {
 }
 
+// SyntaxError is returned by NewEngine for malformed templates.
+type SyntaxError struct {
+	Line int
+	Msg  string
+}
+
+func (self *SyntaxError) Error() string {
+	return fmt.Sprintf("Syntax error on line %d: %s\n", self.Line, self.Msg)
+}
+
 func (self *hamlParser) parse(input string) (output *tree, err error) {
 	output = newTree()
 	var currentNode inode
@@ -22,15 +31,16 @@ func (self *hamlParser) parse(input string) (output *tree, err error) {
 	j := 0
 	for i, r := range input {
 		if r == '\n' {
-			line += 1
 			node, err, lastSpaceChar = parseLeadingSpace(input[j:i], lastSpaceChar, line)
 			if err != nil {
 				return
 			}
 			if node != nil && !node.nil() {
 				putNodeInPlace(currentNode, node, output)
+				output.lines[node] = line
 				currentNode = node
 			}
+			line += 1
 			j = i + 1
 		}
 	}
@@ -40,6 +50,7 @@ func (self *hamlParser) parse(input string) (output *tree, err error) {
 	}
 	if node != nil && !node.nil() {
 		putNodeInPlace(currentNode, node, output)
+		output.lines[node] = line
 	}
 	return
 }
@@ -77,8 +88,12 @@ func parseLeadingSpace(input string, lastSpaceChar rune, line int) (output inode
 			} else {
 				output = parseDoctype("", node, line)
 			}
+		case r == '-' && strings.HasPrefix(input[i+1:], "#"):
+			output = parseComment(input[i+2:], line)
 		case r == '-':
-			output = parseCode(input[i+1:], node, line)
+			output, err = parseCode(input[i+1:], node, line)
+		case r == '+' && isMixinCall(input[i+1:]):
+			output, err = parseCode(input[i:], node, line)
 		case r == '%':
 			output, err = parseTag(input[i+1:], node, true, line)
 		case r == '#':
@@ -98,8 +113,8 @@ func parseLeadingSpace(input string, lastSpaceChar rune, line int) (output inode
 					from = "tab"
 					to = "space"
 				}
-				msg := fmt.Sprintf("Syntax error on line %d: Inconsistent spacing in document changed from %s to %s characters.\n", line, from, to)
-				err = errors.New(msg)
+				msg := fmt.Sprintf("Inconsistent spacing in document changed from %s to %s characters.", from, to)
+				err = &SyntaxError{line, msg}
 			} else {
 				lastSpaceChar = r
 			}
@@ -116,6 +131,13 @@ func parseLeadingSpace(input string, lastSpaceChar rune, line int) (output inode
 	return
 }
 
+// isMixinCall tells whether the text after a + names a mixin, as in +card
+// or +card("Hi"). Other lines starting with + are plain text.
+func isMixinCall(input string) bool {
+	r, _ := utf8.DecodeRuneInString(input)
+	return r == '_' || unicode.IsLetter(r)
+}
+
 func parseDoctype(input string, n *node, line int) (output inode) {
 	output = n
 	n._name = "doctype"
@@ -125,6 +147,11 @@ func parseDoctype(input string, n *node, line int) (output inode) {
 	return
 }
 
+func parseComment(input string, line int) (output inode) {
+	output = &commentnode{_text: t(input)}
+	return
+}
+
 func parseKey(input string, n *node, line int) (output inode) {
 	if input[len(input)-1] == '<' {
 		n = parseNoNewline("", n, line)
@@ -139,7 +166,7 @@ func parseKey(input string, n *node, line int) (output inode) {
 
 func parseTag(input string, node *node, newTag bool, line int) (output inode, err error) {
 	if 0 == len(input) && newTag {
-		err = errors.New(fmt.Sprintf("Syntax error on line %d: Invalid tag: %s.\n", line, input))
+		err = &SyntaxError{line, fmt.Sprintf("Invalid tag: %s.", input)}
 		return
 	}
 	for i, r := range input {
@@ -192,20 +219,25 @@ func parseAttributes(input string, node *node, line int) (output inode, err erro
 		} else if inRocket && r != '>' && r != '=' && r != '}' && !unicode.IsSpace(r) {
 			inRocket = false
 			attrStart = i
+		} else if (r == ',' || r == '}') && attrStart == 0 && isSplat(t(input[0:i])) {
+			node.addSplat(t(input[0:i]))
+			if r == ',' {
+				output, err = parseAttributes(tl(input[i+1:]), node, line)
+			} else {
+				output, _ = parseTag(input[i+1:], node, false, line)
+			}
+			break
 		} else if r == ',' {
 			node.addAttr(t(input[0:keyEnd]), t(input[attrStart:i]))
 			output, err = parseAttributes(tl(input[i+1:]), node, line)
 			break
 		} else if r == '}' {
 			if attrStart == 0 {
-				msg := fmt.Sprintf("Syntax error on line %d: Attribute requires a value.\n", line)
-				//err = os.NewError(msg)
-				err = errors.New(msg)
+				err = &SyntaxError{line, "Attribute requires a value."}
 				return
 			}
 			if inKey {
-				msg := fmt.Sprintf("Syntax error on line %d: Attribute requires a rocket and value.\n", line)
-				err = errors.New(msg)
+				err = &SyntaxError{line, "Attribute requires a rocket and value."}
 				return
 			}
 			attrValue := t(input[attrStart:i])
@@ -215,17 +247,21 @@ func parseAttributes(input string, node *node, line int) (output inode, err erro
 		}
 	}
 	if nil == output {
-		msg := fmt.Sprintf("Syntax error on line %d: Attributes must have closing '}'.\n", line)
-		err = errors.New(msg)
+		err = &SyntaxError{line, "Attributes must have closing '}'."}
 	}
 	return
 }
 
+// isSplat tells whether an attribute written without a rocket is the path
+// of a map or struct of attributes.
+func isSplat(input string) bool {
+	return len(input) > 0 && input[0] != ':' && input[0] != '"' && !strings.ContainsAny(input, " \t=>")
+}
+
 func parseId(input string, node *node, line int) (output inode, err error) {
 	defer func() {
 		if nil == output {
-			msg := fmt.Sprintf("Syntax error on line %d: Illegal element: classes and ids must have values.\n", line)
-			err = errors.New(msg)
+			err = &SyntaxError{line, "Illegal element: classes and ids must have values."}
 		}
 	}()
 	if len(input) == 0 {
@@ -262,8 +298,7 @@ func parseId(input string, node *node, line int) (output inode, err error) {
 func parseClass(input string, node *node, line int) (output inode, err error) {
 	defer func() {
 		if nil == output {
-			msg := fmt.Sprintf("Syntax error on line %d: Illegal element: classes and ids must have values.\n", line)
-			err = errors.New(msg)
+			err = &SyntaxError{line, "Illegal element: classes and ids must have values."}
 		}
 	}()
 	if len(input) == 0 {
@@ -326,27 +361,33 @@ func tl(input string) (output string) {
 	return
 }
 
-func parseCode(input string, node inode, line int) (output inode) {
-	l.init(strings.NewReader(input))
+func parseCode(input string, node inode, line int) (output inode, err error) {
+	// every parse has its own lexer, which the grammar leaves the statement
+	// in, so that templates can be parsed concurrently.
+	l := newLexer(strings.NewReader(input))
 
 	success := yyParse(l)
-	if success != 0 {
-		fmt.Fprintf(os.Stderr, "Did not recognize %s", input)
+	if success != 0 || l.err != "" {
+		err = &SyntaxError{line, fmt.Sprintf("Did not recognize %s (%s).", t(input), l.err)}
+		return
 	}
-	output = Output
+	output = l.output
 	return
 }
 
-// var s scanner.Scanner
-
 type Lexer struct {
-	s *scanner.Scanner
+	s      *scanner.Scanner
+	err    string
+	output inode
 }
 
-var l = &Lexer{new(scanner.Scanner)}
-
-func (l *Lexer) init(reader *strings.Reader) {
+func newLexer(reader *strings.Reader) (l *Lexer) {
+	l = &Lexer{s: new(scanner.Scanner)}
 	l.s.Init(reader)
+	l.s.Error = func(s *scanner.Scanner, msg string) {
+		l.err = msg
+	}
+	return
 }
 
 func (l *Lexer) Lex(v *yySymType) (output int) {
@@ -358,6 +399,10 @@ func (l *Lexer) Lex(v *yySymType) (output int) {
 			output = FOR
 		case "range":
 			output = RANGE
+		case "def":
+			output = DEF
+		case "yield":
+			output = YIELD
 		default:
 			output = IDENT
 		}
@@ -381,5 +426,5 @@ func (l *Lexer) Lex(v *yySymType) (output int) {
 }
 
 func (l *Lexer) Error(e string) {
-	fmt.Fprintf(os.Stderr, "ERROR: %s\n", e)
+	l.err = e
 }
@@ -0,0 +1,148 @@
+package gohaml
+
+import (
+	"html/template"
+	"io"
+	"path"
+	"reflect"
+	"sync"
+	"time"
+)
+
+/*
+Renderer renders the templates of a Loader by name, with any of the values web
+frameworks hand to their view engines as the scope.
+
+The Layout field contains the name of the template every page is wrapped in, or ""
+for none. The layout is rendered after the page, with the scope of the page, including
+the values the page assigned, and the markup of the page as content:
+
+	!!! 5
+	%html
+	  %head
+	    %title= title
+	  %body
+	    = content
+
+A page, or the data it is rendered with, can choose another layout by setting layout
+to its name, or to "" to have none.
+
+The EscapeHTML and FieldLookup fields set the fields of the same names of the engines
+rendering the pages.
+
+A Renderer can be used by several goroutines at once. The templates of a loader
+returned by NewFileSystemLoader are parsed once, and again when they or the partials
+change; those of other loaders are loaded for every page.
+*/
+type Renderer struct {
+	Layout      string
+	EscapeHTML  bool
+	FieldLookup FieldLookup
+	loader      Loader
+
+	sync.Mutex
+	engines map[string]*loadedEngine
+}
+
+// loadedEngine is an engine kept by a Renderer, with the version of its
+// templates it was loaded from.
+type loadedEngine struct {
+	engine  *Engine
+	version time.Time
+}
+
+// NewRenderer returns a Renderer for the templates of the given Loader.
+func NewRenderer(loader Loader) *Renderer {
+	return &Renderer{loader: loader, engines: make(map[string]*loadedEngine)}
+}
+
+// Render renders the template name, to which .haml is added when it has no
+// extension, and writes the markup to w. The data can be any value that
+// Engine.RenderValue accepts.
+func (self *Renderer) Render(w io.Writer, name string, data interface{}) (err error) {
+	var root reflect.Value
+	if root, err = rootValue(data); err != nil {
+		return
+	}
+	scope := make(map[string]interface{})
+
+	var output string
+	if output, err = self.render(name, scope, root); err != nil {
+		return
+	}
+	if layout := self.layout(scope, root); layout != "" {
+		scope["content"] = template.HTML(output)
+		if output, err = self.render(layout, scope, root); err != nil {
+			return
+		}
+	}
+	_, err = io.WriteString(w, output)
+	return
+}
+
+func (self *Renderer) render(name string, scope map[string]interface{}, root reflect.Value) (output string, err error) {
+	if path.Ext(name) == "" {
+		name += ".haml"
+	}
+	var loaded *Engine
+	if loaded, err = self.load(name); err != nil {
+		return
+	}
+	// the engine may be rendering for other goroutines, so the settings go
+	// on a copy.
+	engine := *loaded
+	engine.EscapeHTML = self.EscapeHTML
+	engine.FieldLookup = self.FieldLookup
+	return renderRoot(&engine, scope, root)
+}
+
+// load returns the engine of the template name, parsing it only when it has
+// changed since it was last loaded.
+func (self *Renderer) load(name string) (engine *Engine, err error) {
+	l, ok := self.loader.(*fileSystemLoader)
+	if !ok {
+		return self.loader.Load(name)
+	}
+	var version time.Time
+	if version, err = l.modTime(name); err != nil {
+		return
+	}
+	self.Lock()
+	kept, ok := self.engines[name]
+	self.Unlock()
+	if ok && kept.version.Equal(version) {
+		return kept.engine, nil
+	}
+	if engine, err = l.Load(name); err != nil {
+		return
+	}
+	self.Lock()
+	self.engines[name] = &loadedEngine{engine, version}
+	self.Unlock()
+	return
+}
+
+// layout returns the name of the layout set by the page, by the data or by
+// the Renderer, in that order.
+func (self *Renderer) layout(scope map[string]interface{}, root reflect.Value) string {
+	if v, ok := scope["layout"]; ok {
+		return layoutName(v)
+	}
+	if root.IsValid() {
+		if v := lookupKey(root, "layout", self.FieldLookup); v.IsValid() && v.CanInterface() {
+			return layoutName(v.Interface())
+		}
+	}
+	return self.Layout
+}
+
+// layoutName returns the name of the layout the scope asks for.
+func layoutName(v interface{}) string {
+	switch name := v.(type) {
+	case string:
+		return name
+	case template.HTML:
+		return string(name)
+	}
+	return ""
+}
@@ -0,0 +1,160 @@
+package gohaml
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+type rendererTestPage struct {
+	Title  string
+	Body   template.HTML
+	hidden string
+}
+
+func TestRenderer(t *testing.T) {
+	dir := writeTemplates(t, map[string]string{
+		"layout.haml": "%title= Title\n%body= content",
+		"plain.haml":  "%section= content",
+		"index.haml":  "%p= Body",
+		"title.haml":  "- Title := \"Set by the page\"\n%p",
+		"bare.haml":   "- layout := \"\"\n%p bare",
+		"name.haml":   "%p= name",
+	})
+	loader, _ := NewFileSystemLoader(dir)
+	r := NewRenderer(loader)
+	r.Layout = "layout"
+	page := &rendererTestPage{Title: "Home", Body: "<b>hi</b>", hidden: "x"}
+
+	for i, io := range []struct {
+		name     string
+		data     interface{}
+		expected string
+	}{
+		{"index", page, "<title>Home</title>\n<body><p><b>hi</b></p></body>"},
+		{"index.haml", *page, "<title>Home</title>\n<body><p><b>hi</b></p></body>"},
+		{"title", page, "<title>Set by the page</title>\n<body><p /></body>"},
+		{"bare", nil, "<p>bare</p>"},
+		{"name", map[string]string{"name": "<Tom>", "layout": "plain"}, "<section><p>&lt;Tom&gt;</p></section>"},
+		{"name", map[string]interface{}{"name": "Tom", "layout": ""}, "<p>Tom</p>"},
+	} {
+		r.EscapeHTML = true
+		var buf bytes.Buffer
+		if err := r.Render(&buf, io.name, io.data); err != nil {
+			t.Errorf("(%d) %s: %s", i, io.name, err)
+		} else if buf.String() != io.expected {
+			t.Errorf("(%d) Input    %q\nexpected %q\ngot      %q", i, io.name, io.expected, buf.String())
+		}
+	}
+}
+
+func TestRendererErrors(t *testing.T) {
+	dir := writeTemplates(t, map[string]string{"page.haml": "%p"})
+	loader, _ := NewFileSystemLoader(dir)
+	r := NewRenderer(loader)
+	var buf bytes.Buffer
+	for _, data := range []interface{}{42, []string{"a"}, map[int]string{1: "a"}} {
+		if err := r.Render(&buf, "page", data); err == nil {
+			t.Errorf("expected an error for %#v", data)
+		}
+	}
+	if err := r.Render(&buf, "missing", nil); err == nil {
+		t.Errorf("expected an error for a missing template")
+	}
+	r.Layout = "missing"
+	if err := r.Render(&buf, "page", nil); err == nil {
+		t.Errorf("expected an error for a missing layout")
+	}
+	if buf.Len() != 0 {
+		t.Errorf("expected nothing to be written, got %q", buf.String())
+	}
+}
+
+// TestRendererConcurrent serves pages from one Renderer to many requests at
+// once; run with -race, it checks that parsing and rendering share no state.
+func TestRendererConcurrent(t *testing.T) {
+	templates := map[string]string{
+		"layout.haml":  "%body= content",
+		"_mixins.haml": "- def item(v)\n  %li= v",
+	}
+	for i := 0; i < 8; i++ {
+		templates[fmt.Sprintf("page%d.haml", i)] = fmt.Sprintf("- n := %d\n%%ul\n  - for _, v := range items\n    +item(v)\n%%p= n", i)
+	}
+	loader, _ := NewFileSystemLoader(writeTemplates(t, templates))
+	r := NewRenderer(loader)
+	r.Layout = "layout"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if err := r.Render(w, req.URL.Path[1:], map[string]interface{}{"items": []string{"a", "b"}}); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	// the requests start together, so that the first ones parse the pages
+	// at the same time.
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 64; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			res, err := http.Get(fmt.Sprintf("%s/page%d", server.URL, i%8))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer res.Body.Close()
+			body, _ := io.ReadAll(res.Body)
+			expected := fmt.Sprintf("<body><ul>\n\t<li>a</li>\n\t<li>b</li>\n</ul>\n<p>%d</p></body>", i%8)
+			if string(body) != expected {
+				t.Errorf("(%d) expected %q\ngot      %q", i, expected, body)
+			}
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+}
+
+func TestRendererReload(t *testing.T) {
+	dir := writeTemplates(t, map[string]string{"page.haml": "+title", "_mixins.haml": "- def title\n  %h1 one"})
+	loader, _ := NewFileSystemLoader(dir)
+	r := NewRenderer(loader)
+	render := func() string {
+		var buf bytes.Buffer
+		if err := r.Render(&buf, "page", nil); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+	if output := render(); output != "<h1>one</h1>" {
+		t.Fatalf("unexpected page %q", output)
+	}
+	engine := r.engines["page.haml"].engine
+	render()
+	if r.engines["page.haml"].engine != engine {
+		t.Errorf("expected the engine to be kept")
+	}
+
+	// a changed template or partial is loaded again.
+	later := time.Now().Add(time.Hour)
+	os.WriteFile(filepath.Join(dir, "page.haml"), []byte("+title\n%p two"), 0644)
+	os.Chtimes(filepath.Join(dir, "page.haml"), later, later)
+	if output := render(); output != "<h1>one</h1>\n<p>two</p>" {
+		t.Errorf("expected the changed page, got %q", output)
+	}
+	later = later.Add(time.Hour)
+	os.WriteFile(filepath.Join(dir, "_mixins.haml"), []byte("- def title\n  %h2 three"), 0644)
+	os.Chtimes(filepath.Join(dir, "_mixins.haml"), later, later)
+	if output := render(); output != "<h2>three</h2>\n<p>two</p>" {
+		t.Errorf("expected the changed mixin, got %q", output)
+	}
+}
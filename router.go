@@ -0,0 +1,105 @@
+package gohaml
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// route finds the template that serves a path such as /users/42.haml below
+// the base directory. A file or directory named after a segment of the path
+// is preferred; otherwise a template named [name].haml, or a directory named
+// [name], matches any one segment, and a template named [...name].haml
+// matches the rest of the path. The segments matched this way are returned
+// by name, without the .haml suffix.
+func route(base string, path string) (id string, params map[string]string, ok bool) {
+	segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	for _, segment := range segments {
+		if segment == "" || segment == "." || segment == ".." {
+			return
+		}
+	}
+	params = make(map[string]string)
+	if id, ok = matchRoute(base, segments, params); ok {
+		id = "/" + id
+	}
+	return
+}
+
+func matchRoute(dir string, segments []string, params map[string]string) (id string, ok bool) {
+	name := segments[0]
+	if len(segments) == 1 {
+		if fi, err := os.Stat(filepath.Join(dir, name)); err == nil && !fi.IsDir() {
+			return name, true
+		}
+	} else if fi, err := os.Stat(filepath.Join(dir, name)); err == nil && fi.IsDir() {
+		if id, ok = matchRoute(filepath.Join(dir, name), segments[1:], params); ok {
+			return name + "/" + id, true
+		}
+	}
+
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		param, dynamic := dynamicSegment(e.Name(), e.IsDir())
+		if !dynamic || strings.HasPrefix(param, "...") {
+			continue
+		}
+		switch {
+		case len(segments) == 1 && !e.IsDir() && name != "index.haml":
+			params[param] = strings.TrimSuffix(name, ".haml")
+			return e.Name(), true
+		case len(segments) > 1 && e.IsDir():
+			params[param] = name
+			if id, ok = matchRoute(filepath.Join(dir, e.Name()), segments[1:], params); ok {
+				return e.Name() + "/" + id, true
+			}
+			delete(params, param)
+		}
+	}
+
+	rest := segments
+	if rest[len(rest)-1] == "index.haml" {
+		rest = rest[:len(rest)-1]
+	}
+	if len(rest) == 0 {
+		return
+	}
+	for _, e := range entries {
+		if param, dynamic := dynamicSegment(e.Name(), e.IsDir()); dynamic && !e.IsDir() && strings.HasPrefix(param, "...") {
+			params[param[3:]] = strings.TrimSuffix(strings.Join(rest, "/"), ".haml")
+			return e.Name(), true
+		}
+	}
+	return
+}
+
+// dynamicSegment returns the name of the parameter a file such as [id].haml
+// or a directory such as [id] stands for.
+func dynamicSegment(name string, dir bool) (param string, ok bool) {
+	if !dir {
+		if !strings.HasSuffix(name, ".haml") {
+			return
+		}
+		name = strings.TrimSuffix(name, ".haml")
+	}
+	if len(name) > 2 && name[0] == '[' && name[len(name)-1] == ']' {
+		return name[1 : len(name)-1], true
+	}
+	return
+}
+
+type paramsKey struct{}
+
+// PathParam returns the part of the path of r matched by the dynamic
+// segment called name in the route of a handler returned by NewHamlHandler,
+// such as id for users/[id].haml, or "" if there is none.
+func PathParam(r *http.Request, name string) string {
+	params, _ := r.Context().Value(paramsKey{}).(map[string]string)
+	return params[name]
+}
+
+func withParams(r *http.Request, params map[string]string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), paramsKey{}, params))
+}
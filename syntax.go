@@ -0,0 +1,141 @@
+package gohaml
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/realistschuckle/gohaml/ast"
+)
+
+// Parse parses a template into its syntax tree.
+func Parse(src string) (file *ast.File, err error) {
+	var t *tree
+	if t, err = parser.parse(src); err != nil {
+		return
+	}
+	file = &ast.File{Nodes: toAST(t, t.nodes)}
+	return
+}
+
+func toAST(t *tree, nodes []inode) (output []ast.Node) {
+	for _, n := range nodes {
+		output = append(output, toASTNode(t, n))
+	}
+	return
+}
+
+func toASTNode(t *tree, n inode) ast.Node {
+	pos := ast.Pos{Line: t.lines[n], Column: n.indentLevel() + 1}
+	switch n := n.(type) {
+	case *node:
+		content := ast.Value{Text: n._remainder.value, IsPath: n._remainder.needsResolution}
+		if n._name == "doctype" {
+			return &ast.Doctype{Pos: pos, Value: strings.TrimSpace(n._remainder.value)}
+		}
+		if n._name == "" && len(n._attrs) == 0 {
+			return &ast.Text{Pos: pos, Content: content, NoNewline: n._noNewline}
+		}
+		tag := &ast.Tag{Pos: pos, Name: n._name, Content: content, NoNewline: n._noNewline, SelfClose: n._autoclose}
+		if tag.Name == "" {
+			tag.Name = "div"
+		}
+		for _, attr := range n._attrs {
+			tag.Attrs = append(tag.Attrs, &ast.Attr{
+				Key:   ast.Value{Text: attr.key.value, IsPath: attr.key.needsResolution},
+				Value: ast.Value{Text: attr.value.value, IsPath: attr.value.needsResolution},
+			})
+		}
+		tag.Children = toAST(t, n._children)
+		return tag
+	case *commentnode:
+		return &ast.Comment{Pos: pos, Text: n._text, Children: toAST(t, n._children)}
+	case *declassnode:
+		return &ast.Assign{Pos: pos, Name: n._lhs, Value: ast.Expr{Literal: n._rhs}}
+	case *vdeclassnode:
+		return &ast.Assign{Pos: pos, Name: n._lhs, Value: ast.Expr{Path: n._rhs.value}}
+	case *rangenode:
+		return &ast.Range{Pos: pos, Key: n._lhs1, Value: n._lhs2, Target: ast.Expr{Literal: n._count, Path: n._rhs.value}, Children: toAST(t, n._children)}
+	case *defnode:
+		return &ast.Def{Pos: pos, Name: n._name, Params: n._params, Children: toAST(t, n._children)}
+	case *callnode:
+		call := &ast.Call{Pos: pos, Name: n._name, Children: toAST(t, n._children)}
+		for _, arg := range n._args {
+			call.Args = append(call.Args, ast.Expr{Literal: arg._atom, Path: arg._path.value})
+		}
+		return call
+	case *yieldnode:
+		return &ast.Yield{Pos: pos}
+	}
+	panic("gohaml: unexpected node type")
+}
+
+// fromAST builds the tree the engine renders from a syntax tree. Nil nodes
+// and attributes, which a Transformer may leave behind, are errors.
+func fromAST(file *ast.File) (t *tree, err error) {
+	t = newTree()
+	for _, n := range file.Nodes {
+		var output inode
+		if output, err = fromASTNode(t, n); err != nil {
+			return nil, err
+		}
+		t.nodes = append(t.nodes, output)
+	}
+	return
+}
+
+func fromASTNode(t *tree, n ast.Node) (output inode, err error) {
+	if n == nil || reflect.ValueOf(n).IsNil() {
+		return nil, errors.New("gohaml: nil node in syntax tree")
+	}
+	var children []ast.Node
+	switch n := n.(type) {
+	case *ast.Doctype:
+		output = &node{_name: "doctype", _remainder: res{n.Value, false}}
+	case *ast.Text:
+		output = &node{_remainder: res{n.Content.Text, n.Content.IsPath}, _noNewline: n.NoNewline}
+	case *ast.Tag:
+		tag := &node{_name: n.Name, _remainder: res{n.Content.Text, n.Content.IsPath}, _noNewline: n.NoNewline, _autoclose: n.SelfClose}
+		for _, attr := range n.Attrs {
+			if attr == nil {
+				return nil, fmt.Errorf("gohaml: nil attribute of %s on line %d", n.Name, n.Pos.Line)
+			}
+			tag._attrs = append(tag._attrs, &resPair{res{attr.Key.Text, attr.Key.IsPath}, res{attr.Value.Text, attr.Value.IsPath}})
+		}
+		output, children = tag, n.Children
+	case *ast.Comment:
+		output, children = &commentnode{_text: n.Text}, n.Children
+	case *ast.Assign:
+		if n.Value.Path != "" {
+			output = &vdeclassnode{_lhs: n.Name, _rhs: res{n.Value.Path, true}}
+		} else {
+			output = &declassnode{_lhs: n.Name, _rhs: n.Value.Literal}
+		}
+	case *ast.Range:
+		output, children = &rangenode{_lhs1: n.Key, _lhs2: n.Value, _rhs: res{n.Target.Path, n.Target.Path != ""}, _count: n.Target.Literal}, n.Children
+	case *ast.Def:
+		output, children = &defnode{_name: n.Name, _params: n.Params}, n.Children
+	case *ast.Call:
+		call := &callnode{_name: n.Name}
+		for _, arg := range n.Args {
+			call._args = append(call._args, mixinarg{arg.Literal, res{arg.Path, arg.Path != ""}})
+		}
+		output, children = call, n.Children
+	case *ast.Yield:
+		output = new(yieldnode)
+	default:
+		return nil, fmt.Errorf("gohaml: unexpected node type %T", n)
+	}
+	pos := n.Position()
+	output.setIndentLevel(pos.Column - 1)
+	t.lines[output] = pos.Line
+	for _, child := range children {
+		var c inode
+		if c, err = fromASTNode(t, child); err != nil {
+			return nil, err
+		}
+		output.addChild(c)
+	}
+	return
+}
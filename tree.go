@@ -2,11 +2,23 @@ package gohaml
 
 import (
 	"bytes"
+	"encoding"
 	"fmt"
+	"html/template"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 )
 
+// maxMixinDepth bounds how deeply mixin calls may nest. Calls beyond this
+// depth render nothing and fail the render, which stops runaway recursion
+// in templates.
+const maxMixinDepth = 100
+
 type res struct {
 	value           string
 	needsResolution bool
@@ -17,13 +29,19 @@ type resPair struct {
 	value res
 }
 
+// isSplat tells whether the pair stands for a map or struct of attributes
+// rather than a single key and value.
+func (self *resPair) isSplat() bool {
+	return self.key.value == ""
+}
+
 type inode interface {
 	parent() inode
 	indentLevel() int
 	setIndentLevel(i int)
 	addChild(n inode)
 	noNewline() bool
-	resolve(scope map[string]interface{}, buf *bytes.Buffer, curIndent string, indent string, autoclose bool)
+	resolve(scope map[string]interface{}, buf *bytes.Buffer, curIndent string, r *renderState)
 	setParent(n inode)
 	nil() bool
 }
@@ -35,7 +53,7 @@ type icodenode interface {
 	setIndentLevel(i int)
 	addChild(n inode)
 	noNewline() bool
-	resolve(scope map[string]interface{}, buf *bytes.Buffer, curIndent string, indent string, autoclose bool)
+	resolve(scope map[string]interface{}, buf *bytes.Buffer, curIndent string, r *renderState)
 	setParent(n inode)
 	nil() bool
 }
@@ -53,78 +71,268 @@ type node struct {
 
 type tree struct {
 	nodes []inode
+	lines map[inode]int
+}
+
+// renderState carries the engine settings and the mixin bookkeeping through
+// a single call to tree.resolve.
+type renderState struct {
+	indent     string
+	autoclose  bool
+	mixins     map[string]*defnode
+	shared     *mixinRegistry
+	frames     []*mixinFrame
+	depth      int
+	formatters map[reflect.Type]Formatter
+	escape     bool
+	root       reflect.Value
+	lookup     FieldLookup
+	err        error
+}
+
+// fail records the first error of the render.
+func (self *renderState) fail(err error) {
+	if self.err == nil {
+		self.err = err
+	}
+}
+
+// mixinFrame records the block passed to a mixin call together with the
+// scope of the caller, so that yield renders the block where it was written.
+type mixinFrame struct {
+	block []inode
+	scope map[string]interface{}
+}
+
+// mixinRegistry holds mixin definitions shared between the engines created by
+// a Loader.
+type mixinRegistry struct {
+	sync.RWMutex
+	defs map[string]*defnode
 }
 
 func newTree() (output *tree) {
-	output = &tree{}
+	output = &tree{lines: make(map[inode]int)}
 	return
 }
 
-func (self res) resolve(scope map[string]interface{}) (output string) {
+func (self res) resolve(scope map[string]interface{}, r *renderState) (output string) {
 	output = self.value
 	if self.needsResolution {
-		curr := self.resolveValue(scope)
+		output = formatText(self.resolveValue(scope, r), r.formatters, r.escape)
+	}
+	return
+}
 
-	OutputSwitch:
-		switch t := curr; t.Kind() {
-		case reflect.String:
-			output = t.String()
-		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-			output = fmt.Sprint(t.Int())
-		case reflect.Float32, reflect.Float64:
-			output = fmt.Sprint(t.Float())
-		case reflect.Ptr:
-			if !t.IsNil() {
-				curr = t.Elem()
-				goto OutputSwitch
-			}
-			output = ""
-		case reflect.Interface:
+// defaultFormatters are used for the types that have no Formatter set on
+// the engine.
+var defaultFormatters = map[reflect.Type]Formatter{
+	reflect.TypeOf(time.Time{}): func(v interface{}) string {
+		return v.(time.Time).Format(time.RFC3339)
+	},
+}
+
+// formatValue converts a value to text with the Formatter for its type, then
+// with its Error, String or MarshalText method, and otherwise by its kind.
+// Pointers and interfaces are followed until one of those applies.
+func formatValue(curr reflect.Value, formatters map[reflect.Type]Formatter) (output string) {
+OutputSwitch:
+	if s, ok := formatCustom(curr, formatters); ok {
+		return s
+	}
+	switch t := curr; t.Kind() {
+	case reflect.String:
+		output = t.String()
+	case reflect.Bool:
+		output = strconv.FormatBool(t.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		output = strconv.FormatInt(t.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		output = strconv.FormatUint(t.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		output = fmt.Sprint(t.Float())
+	case reflect.Ptr:
+		if !t.IsNil() {
 			curr = t.Elem()
 			goto OutputSwitch
-		default:
-			output = fmt.Sprint(curr)
+		}
+		output = ""
+	case reflect.Interface:
+		curr = t.Elem()
+		goto OutputSwitch
+	case reflect.Invalid:
+		output = ""
+	default:
+		output = fmt.Sprint(curr)
+	}
+	return
+}
+
+// formatCustom converts a value with its Formatter or with its own method,
+// if it has either.
+func formatCustom(v reflect.Value, formatters map[reflect.Type]Formatter) (output string, ok bool) {
+	if !v.IsValid() || !v.CanInterface() {
+		return
+	}
+	if f, found := formatters[v.Type()]; found {
+		return f(v.Interface()), true
+	}
+	if f, found := defaultFormatters[v.Type()]; found {
+		return f(v.Interface()), true
+	}
+	return formatMethod(v)
+}
+
+// formatMethod converts a value that formats itself, leaving nil pointers
+// and interfaces to formatValue.
+func formatMethod(v reflect.Value) (output string, ok bool) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return
+		}
+	}
+	switch t := v.Interface().(type) {
+	case error:
+		return t.Error(), true
+	case fmt.Stringer:
+		return t.String(), true
+	case encoding.TextMarshaler:
+		if b, err := t.MarshalText(); err == nil {
+			return string(b), true
 		}
 	}
 	return
 }
 
-func (self res) resolveValue(scope map[string]interface{}) (value reflect.Value) {
+// resolveValue looks up a path in the scope. The first name of the path is
+// looked up in the root value being rendered when the scope does not hold
+// it.
+func (self res) resolveValue(scope map[string]interface{}, r *renderState) (value reflect.Value) {
 	keyPath := strings.Split(self.value, ".")
-	curr := reflect.ValueOf(scope[keyPath[0]])
+	v, ok := scope[keyPath[0]]
+	curr := reflect.ValueOf(v)
+	if !ok && r.root.IsValid() {
+		curr = lookupKey(r.root, keyPath[0], r.lookup)
+	}
 	for _, key := range keyPath[1:] {
-	TypeSwitch:
-		switch t := curr; t.Kind() {
-		case reflect.Ptr:
-			curr = t.Elem()
-			goto TypeSwitch
-		case reflect.Struct:
-			curr = t.FieldByName(key)
-		case reflect.Map:
-			curr = t.MapIndex(reflect.ValueOf(key))
-		}
+		curr = lookupKey(curr, key, r.lookup)
 	}
 	value = curr
 	return
 }
 
-func (self tree) resolve(scope map[string]interface{}, indent string, autoclose bool) (output string) {
-	//treeLen := self.nodes.Len()
-	treeLen := len(self.nodes)
+// lookupKey returns the niladic method, the field or the map entry of curr
+// with the given name, matching the names of methods and fields as lookup
+// says.
+func lookupKey(curr reflect.Value, key string, lookup FieldLookup) reflect.Value {
+	if curr.IsValid() {
+		if m := niladicMethod(curr, lookup.method(curr.Type(), key)); m.IsValid() {
+			return m.Call(nil)[0]
+		}
+	}
+TypeSwitch:
+	switch t := curr; t.Kind() {
+	case reflect.Ptr:
+		curr = t.Elem()
+		goto TypeSwitch
+	case reflect.Struct:
+		index, _, ok := lookup.field(t.Type(), key)
+		if !ok {
+			return reflect.Value{}
+		}
+		curr, _ = t.FieldByIndexErr(index)
+	case reflect.Interface:
+		curr = t.Elem()
+		goto TypeSwitch
+	case reflect.Map:
+		if t.Type().Key().Kind() != reflect.String {
+			return reflect.Value{}
+		}
+		curr = t.MapIndex(reflect.ValueOf(key).Convert(t.Type().Key()))
+	}
+	return curr
+}
+
+// niladicMethod returns the method of v with the given name if it takes no
+// arguments and returns a single value, so that paths can call it.
+func niladicMethod(v reflect.Value, name string) (m reflect.Value) {
+	if !v.IsValid() || (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+		return
+	}
+	if m = v.MethodByName(name); m.IsValid() && (m.Type().NumIn() != 0 || m.Type().NumOut() != 1) {
+		m = reflect.Value{}
+	}
+	return
+}
+
+func newMixinRegistry() *mixinRegistry {
+	return &mixinRegistry{defs: make(map[string]*defnode)}
+}
+
+// set replaces the definitions of the registry.
+func (self *mixinRegistry) set(defs map[string]*defnode) {
+	self.Lock()
+	defer self.Unlock()
+	self.defs = defs
+}
+
+func (self *mixinRegistry) lookup(name string) *defnode {
+	self.RLock()
+	defer self.RUnlock()
+	return self.defs[name]
+}
+
+func (self *renderState) lookupMixin(name string) *defnode {
+	if def, ok := self.mixins[name]; ok {
+		return def
+	}
+	if self.shared != nil {
+		return self.shared.lookup(name)
+	}
+	return nil
+}
+
+// mixins collects the mixin definitions found anywhere in the tree.
+func (self tree) mixins() (defs map[string]*defnode) {
+	defs = make(map[string]*defnode)
+	var collect func(nodes []inode)
+	collect = func(nodes []inode) {
+		for _, n := range nodes {
+			switch t := n.(type) {
+			case *defnode:
+				defs[t._name] = t
+			case *node:
+				collect(t._children)
+			case *rangenode:
+				collect(t._children)
+			case *callnode:
+				collect(t._children)
+			}
+		}
+	}
+	collect(self.nodes)
+	return
+}
+
+func (self tree) resolve(scope map[string]interface{}, r *renderState) (output string) {
 	buf := bytes.NewBuffer(make([]byte, 0))
-	for i, n := range self.nodes {
-		node := n
-		node.resolve(scope, buf, "", indent, autoclose)
-		if i != treeLen-1 && !node.noNewline() {
+	newline := false
+	for _, n := range rendered(self.nodes) {
+		if newline && !isAssignment(n) {
 			buf.WriteString("\n")
 		}
+		n.resolve(scope, buf, "", r)
+		if !isAssignment(n) {
+			newline = !n.noNewline()
+		}
 	}
 	output = buf.String()
 	return
 }
 
-func (self node) resolve(scope map[string]interface{}, buf *bytes.Buffer, curIndent string, indent string, autoclose bool) {
-	remainder := self._remainder.resolve(scope)
+func (self node) resolve(scope map[string]interface{}, buf *bytes.Buffer, curIndent string, r *renderState) {
+	remainder := self._remainder.resolve(scope, r)
 	if self._name == "doctype" {
 		buf.WriteString("<!DOCTYPE html")
 		switch strings.TrimSpace(self._remainder.value) {
@@ -150,7 +358,7 @@ func (self node) resolve(scope map[string]interface{}, buf *bytes.Buffer, curInd
 		}
 		buf.WriteString("<")
 		buf.WriteString(self._name)
-		self.resolveAttrs(scope, buf)
+		self.resolveAttrs(scope, buf, r)
 		buf.WriteString(">")
 		buf.WriteString(remainder)
 		buf.WriteString("</")
@@ -163,8 +371,8 @@ func (self node) resolve(scope map[string]interface{}, buf *bytes.Buffer, curInd
 		}
 		buf.WriteString("<")
 		buf.WriteString(self._name)
-		self.resolveAttrs(scope, buf)
-		self.outputChildren(scope, buf, curIndent, indent, autoclose)
+		self.resolveAttrs(scope, buf, r)
+		self.outputChildren(scope, buf, curIndent, r)
 	} else if len(self._name) > 0 && len(remainder) > 0 {
 		buf.WriteString("<")
 		buf.WriteString(self._name)
@@ -176,29 +384,34 @@ func (self node) resolve(scope map[string]interface{}, buf *bytes.Buffer, curInd
 	} else if len(self._name) > 0 {
 		buf.WriteString("<")
 		buf.WriteString(self._name)
-		self.outputChildren(scope, buf, curIndent, indent, autoclose)
+		self.outputChildren(scope, buf, curIndent, r)
 	} else {
 		buf.WriteString(remainder)
 	}
 }
 
-func (self node) outputChildren(scope map[string]interface{}, buf *bytes.Buffer, curIndent string, indent string, autoclose bool) {
-	ind := curIndent + indent
+func (self node) outputChildren(scope map[string]interface{}, buf *bytes.Buffer, curIndent string, r *renderState) {
+	ind := curIndent + r.indent
 	if self._noNewline {
 		ind = curIndent
 	}
 	//childLen := self._children.Len()
-	childLen := len(self._children)
+	children := rendered(self._children)
+	childLen := len(children)
 	if childLen > 0 {
 		buf.WriteString(">")
-		for i, n := range self._children {
-			//node := n.(inode)
-			node := n
-			if i != 0 || !self._noNewline {
+		first := true
+		for _, n := range children {
+			if isAssignment(n) {
+				n.resolve(scope, buf, ind, r)
+				continue
+			}
+			if !first || !self._noNewline {
 				buf.WriteString("\n")
 				buf.WriteString(ind)
 			}
-			node.resolve(scope, buf, ind, indent, autoclose)
+			n.resolve(scope, buf, ind, r)
+			first = false
 		}
 		if !self._noNewline {
 			buf.WriteString("\n")
@@ -208,7 +421,7 @@ func (self node) outputChildren(scope map[string]interface{}, buf *bytes.Buffer,
 		buf.WriteString(self._name)
 		buf.WriteString(">")
 	} else {
-		if autoclose || self._autoclose {
+		if r.autoclose || self._autoclose {
 			buf.WriteString(" />")
 		} else {
 			buf.WriteString(">")
@@ -225,32 +438,158 @@ func contains(value string, slice []string) bool {
 	return false
 }
 
-func (self node) resolveAttrs(scope map[string]interface{}, buf *bytes.Buffer) {
-	attrMap := make(map[string]string)
-
-	// for i := 0; i < self._attrs.Len(); i++ {
+func (self node) resolveAttrs(scope map[string]interface{}, buf *bytes.Buffer, r *renderState) {
+	var pairs []reflect.Value
 	for _, resPair := range self._attrs {
-		//resPair := self._attrs.At(i).(*resPair)
-		key, value := resPair.key.resolve(scope), resPair.value.resolve(scope)
-		if _, ok := attrMap[key]; ok {
-			attrMap[key] += " " + value
-		} else {
-			attrMap[key] = value
+		if resPair.isSplat() {
+			pairs = append(pairs, splatAttrs(resPair.value.resolveValue(scope, r))...)
+			continue
+		}
+		pairs = append(pairs, resPair.key.resolveAttr(scope, r), resPair.value.resolveAttr(scope, r))
+	}
+	writeAttrs(buf, pairs, r.formatters, r.escape)
+}
+
+// splatAttrs returns the keys and values of a map, in key order, or the
+// names and values of the exported fields of a struct. A field is named by
+// its haml tag, or by its name in lower case; the tag "-" skips the field
+// and the option omitempty skips it when it holds its zero value, as in
+// `haml:"data-id,omitempty"`.
+func splatAttrs(v reflect.Value) (pairs []reflect.Value) {
+	for (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && !v.IsNil() {
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Map:
+		for _, key := range sortedKeys(v) {
+			pairs = append(pairs, key, v.MapIndex(key))
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			name, opts, _ := strings.Cut(f.Tag.Get("haml"), ",")
+			switch {
+			case name == "-":
+				continue
+			case name == "":
+				name = strings.ToLower(f.Name)
+			}
+			if opts == "omitempty" && v.Field(i).IsZero() {
+				continue
+			}
+			pairs = append(pairs, reflect.ValueOf(name), v.Field(i))
 		}
 	}
-	// don't iterate over map in order to preserve the order in which
+	return
+}
+
+// resolveAttr returns the value of an attribute key or value before it is
+// converted to text, so that lists and maps can be merged. Text written in
+// the template is returned as template.HTML, so that it is never escaped.
+func (self res) resolveAttr(scope map[string]interface{}, r *renderState) reflect.Value {
+	if self.needsResolution {
+		return self.resolveValue(scope, r)
+	}
+	return reflect.ValueOf(template.HTML(self.value))
+}
+
+// attrParts converts the value of an attribute to the words it contributes.
+// Slices, arrays and maps are lists: their elements, or the keys of a map
+// whose values are set, each contribute their words. Nil and false
+// contribute nothing.
+func attrParts(v reflect.Value, formatters map[reflect.Type]Formatter, escape bool) (parts []string) {
+	for v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Invalid:
+		return
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+		if v.IsNil() {
+			return
+		}
+	case reflect.Bool:
+		if !v.Bool() {
+			return
+		}
+	}
+	if _, ok := formatCustom(v, formatters); ok {
+		return []string{formatText(v, formatters, escape)}
+	}
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			p := attrParts(v.Index(i), formatters, escape)
+			parts = append(parts, p...)
+		}
+		return parts
+	case reflect.Map:
+		for _, key := range sortedKeys(v) {
+			if p := attrParts(v.MapIndex(key), formatters, escape); len(p) > 0 {
+				parts = append(parts, formatText(key, formatters, escape))
+			}
+		}
+		return parts
+	}
+	return []string{formatText(v, formatters, escape)}
+}
+
+// attr collects the values given for one attribute key.
+type attr struct {
+	key   string
+	parts []string
+}
+
+// value joins the values of an attribute the way Haml does: ids are always
+// joined with underscores, and classes are split into words, sorted and
+// stripped of duplicates.
+func (self *attr) value() string {
+	switch self.key {
+	case "id":
+		return strings.Join(self.parts, "_")
+	case "class":
+		var classes []string
+		for _, part := range self.parts {
+			for _, class := range strings.Fields(part) {
+				if !contains(class, classes) {
+					classes = append(classes, class)
+				}
+			}
+		}
+		sort.Strings(classes)
+		return strings.Join(classes, " ")
+	}
+	return strings.Join(self.parts, " ")
+}
+
+// writeAttrs writes the attributes given as alternating keys and values,
+// joining the values of duplicate keys and leaving out the attributes whose
+// values are all nil or false. When escape is set, the values are escaped
+// unless they are template.HTML, and attributes whose names could not be
+// written safely are left out.
+func writeAttrs(buf *bytes.Buffer, pairs []reflect.Value, formatters map[reflect.Type]Formatter, escape bool) {
+	// don't iterate over a map in order to preserve the order in which
 	// the attributes were collected.
-	//	for key, value := range attrMap {
-	var seenKeys []string
-	for _, resPair := range self._attrs {
-		key := resPair.key.resolve(scope)
-		if contains(key, seenKeys) {
-			continue
+	var attrs []*attr
+	byKey := make(map[string]*attr)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		key := formatValue(pairs[i], formatters)
+		a, ok := byKey[key]
+		if !ok {
+			a = &attr{key: key}
+			byKey[key] = a
+			attrs = append(attrs, a)
 		}
-		seenKeys = append(seenKeys, key)
+		a.parts = append(a.parts, attrParts(pairs[i+1], formatters, escape)...)
+	}
 
-		value := attrMap[key]
-		if value == "false" {
+	for _, a := range attrs {
+		key, value := a.key, a.value()
+		if len(a.parts) == 0 || value == "false" || escape && !validAttrName(key) {
 			continue
 		}
 		buf.WriteString(" ")
@@ -288,6 +627,10 @@ func (self *node) addAttr(key string, value string) {
 	self._attrs = append(self._attrs, &resPair{res{key, keyLookup}, res{value, valueLookup}})
 }
 
+func (self *node) addSplat(path string) {
+	self._attrs = append(self._attrs, &resPair{res{}, res{path, true}})
+}
+
 func (self *node) addAttrNoLookup(key string, value string) {
 	//self._attrs.Push(&resPair{res{key, false}, res{value, false}})
 	self._attrs = append(self._attrs, &resPair{res{key, false}, res{value, false}})
@@ -333,6 +676,7 @@ type rangenode struct {
 
 	_lhs1, _lhs2 string
 	_rhs         res
+	_count       interface{}
 }
 
 func (self *rangenode) parent() inode {
@@ -357,113 +701,195 @@ func (self *rangenode) noNewline() bool {
 	return false
 }
 
-func (self *rangenode) resolve(scope map[string]interface{}, buf *bytes.Buffer, curIndent string, indent string, autoclose bool) {
-	//oldlhs1, oklhs1 := scope[self._lhs1]
-	//oldlhs2, oklhs2 := scope[self._lhs2]
-
-	__lhs1 := scope[self._lhs1]
-	__lhs2 := scope[self._lhs2]
-
-	value := self._rhs.resolveValue(scope)
+func (self *rangenode) resolve(scope map[string]interface{}, buf *bytes.Buffer, curIndent string, r *renderState) {
+	value := reflect.ValueOf(self._count)
+	if self._rhs.needsResolution {
+		value = self._rhs.resolveValue(scope, r)
+	}
 
-	switch t := value; t.Kind() {
-	case reflect.Slice:
-		for i := 0; i < t.Len(); i++ {
-			v := t.Index(i)
-			var iv interface{}
+	names := []string{self._lhs1, self._lhs2, "loop"}
+	saved := make([]interface{}, len(names))
+	present := make([]bool, len(names))
+	for i, name := range names {
+		saved[i], present[i] = scope[name]
+	}
+	parent, _ := scope["loop"].(*Loop)
+
+	newline := false
+	rangeItems(value, func(item rangeItem) {
+		if self._lhs2 == "" && item.keyless {
+			// like Go, a single variable gets the values of a channel or
+			// an iter.Seq.
+			bindRangeVar(scope, self._lhs1, item.value)
+		} else {
+			bindRangeVar(scope, self._lhs1, item.key)
+			bindRangeVar(scope, self._lhs2, item.value)
+		}
+		loop := newLoop(item.index, item.length, parent)
+		loop.Last = item.last
+		scope["loop"] = loop
+		newline = resolveChildren(self._children, scope, buf, curIndent, r, newline)
+	})
+
+	for i, name := range names {
+		if present[i] {
+			bindRangeVar(scope, name, saved[i])
+		} else if name != "" {
+			delete(scope, name)
+		}
+	}
+}
 
-			switch t := v; t.Kind() {
-			case reflect.String:
-				iv = t.String()
-			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-				iv = fmt.Sprint(t.Int())
-			case reflect.Float32, reflect.Float64:
-				iv = fmt.Sprint(t.Float())
-			case reflect.Struct:
-				iv = t.Interface()
-			}
+func bindRangeVar(scope map[string]interface{}, name string, value interface{}) {
+	if name != "" && name != "_" {
+		scope[name] = value
+	}
+}
 
-			scope[self._lhs1] = i
-			scope[self._lhs2] = iv
+// rangeItem is one iteration of a range: what it binds to the two loop
+// variables, whether the key is a mere count, and its position. The length
+// is -1 when it is not known in advance.
+type rangeItem struct {
+	key     interface{}
+	value   interface{}
+	keyless bool
+	index   int
+	length  int
+	last    bool
+}
 
-			for _, n := range self._children {
-				node := n
-				node.resolve(scope, buf, curIndent, indent, autoclose)
-				if i != t.Len()-1 && !node.noNewline() {
-					buf.WriteString("\n")
-					buf.WriteString(curIndent)
-				}
-			}
+// rangeItems calls each for every iteration of a range over v, in order.
+// Slices, arrays, maps and strings bind their indexes, keys or byte offsets
+// and their elements as they are, with maps in the order of their keys and
+// strings yielding one character at a time. Integers bind 0 to n-1 to both
+// variables. Channels bind a count and the values received until they are
+// closed, functions such as iter.Seq bind a count and the yielded value, and
+// iter.Seq2 functions bind both yielded values. Channels and functions are
+// consumed as the loop runs, only one item ahead of the iteration being
+// rendered so that it knows whether it is the last.
+func rangeItems(v reflect.Value, each func(item rangeItem)) {
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	index := 0
+	add := func(k interface{}, x interface{}, length int) {
+		each(rangeItem{key: k, value: x, index: index, length: length, last: index == length-1})
+		index++
+	}
+	var pending *rangeItem
+	push := func(k interface{}, x interface{}, keyless bool) {
+		if pending != nil {
+			each(*pending)
 		}
-	case reflect.Array:
-		for i := 0; i < t.Len(); i++ {
-			v := t.Index(i)
-			var iv interface{}
-
-			switch t := v; t.Kind() {
-			case reflect.String:
-				iv = t.String()
-			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-				iv = fmt.Sprint(t.Int())
-			case reflect.Float32, reflect.Float64:
-				iv = fmt.Sprint(t.Float())
-			case reflect.Struct:
-				iv = t.Interface()
-			}
-
-			scope[self._lhs1] = i
-			scope[self._lhs2] = iv
-
-			for _, n := range self._children {
-				node := n
-				node.resolve(scope, buf, curIndent, indent, autoclose)
-				if i != t.Len()-1 && !node.noNewline() {
-					buf.WriteString("\n")
-					buf.WriteString(curIndent)
-				}
-			}
+		pending = &rangeItem{key: k, value: x, keyless: keyless, index: index, length: -1}
+		index++
+	}
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			add(i, rangeValue(v.Index(i)), v.Len())
 		}
 	case reflect.Map:
-		for i, k := range t.MapKeys() {
-			v := t.MapIndex(k)
-
-			var iv interface{}
-
-			switch t := k; t.Kind() {
-			case reflect.String:
-				iv = t.String()
-			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-				iv = fmt.Sprint(t.Int())
-			case reflect.Float32, reflect.Float64:
-				iv = fmt.Sprint(t.Float())
+		keys := sortedKeys(v)
+		for _, k := range keys {
+			add(rangeValue(k), rangeValue(v.MapIndex(k)), len(keys))
+		}
+	case reflect.String:
+		length := utf8.RuneCountInString(v.String())
+		for i, c := range v.String() {
+			add(i, string(c), length)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		for i := 0; i < int(v.Int()); i++ {
+			add(i, i, int(v.Int()))
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		for i := 0; uint64(i) < v.Uint(); i++ {
+			add(i, i, int(v.Uint()))
+		}
+	case reflect.Chan:
+		if v.Type().ChanDir()&reflect.RecvDir == 0 {
+			return
+		}
+		for i := 0; ; i++ {
+			x, ok := v.Recv()
+			if !ok {
+				break
 			}
+			push(i, rangeValue(x), true)
+		}
+	case reflect.Func:
+		if !isSeq(v.Type()) {
+			return
+		}
+		yield := v.Type().In(0)
+		more := reflect.ValueOf(true).Convert(yield.Out(0))
+		v.Call([]reflect.Value{reflect.MakeFunc(yield, func(args []reflect.Value) []reflect.Value {
+			if len(args) == 2 {
+				push(rangeValue(args[0]), rangeValue(args[1]), false)
+			} else {
+				push(index, rangeValue(args[0]), true)
+			}
+			return []reflect.Value{more}
+		})})
+	}
+	if pending != nil {
+		pending.last = true
+		each(*pending)
+	}
+}
 
-			scope[self._lhs1] = iv
+// isSeq tells whether t has the shape of iter.Seq or iter.Seq2.
+func isSeq(t reflect.Type) bool {
+	if t.NumIn() != 1 || t.NumOut() != 0 || t.In(0).Kind() != reflect.Func {
+		return false
+	}
+	yield := t.In(0)
+	return (yield.NumIn() == 1 || yield.NumIn() == 2) && yield.NumOut() == 1 && yield.Out(0).Kind() == reflect.Bool
+}
 
-			switch t := v; t.Kind() {
-			case reflect.String:
-				iv = t.String()
-			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-				iv = fmt.Sprint(t.Int())
-			case reflect.Float32, reflect.Float64:
-				iv = fmt.Sprint(t.Float())
-			}
+// rangeValue returns the value a loop variable is bound to.
+func rangeValue(v reflect.Value) interface{} {
+	if !v.IsValid() || !v.CanInterface() {
+		return nil
+	}
+	return v.Interface()
+}
 
-			scope[self._lhs2] = iv
+// sortedKeys returns the keys of a map in order, so that ranges over maps
+// render the same markup every time.
+func sortedKeys(m reflect.Value) []reflect.Value {
+	keys := m.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return lessValue(keys[i], keys[j])
+	})
+	return keys
+}
 
-			for _, n := range self._children {
-				node := n
-				node.resolve(scope, buf, curIndent, indent, autoclose)
-				if i != t.Len()-1 && !node.noNewline() {
-					buf.WriteString("\n")
-					buf.WriteString(curIndent)
-				}
-			}
+func lessValue(a, b reflect.Value) bool {
+	for a.Kind() == reflect.Interface && !a.IsNil() {
+		a = a.Elem()
+	}
+	for b.Kind() == reflect.Interface && !b.IsNil() {
+		b = b.Elem()
+	}
+	if a.Kind() == b.Kind() {
+		switch a.Kind() {
+		case reflect.String:
+			return a.String() < b.String()
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return a.Int() < b.Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			return a.Uint() < b.Uint()
+		case reflect.Float32, reflect.Float64:
+			return a.Float() < b.Float()
+		case reflect.Bool:
+			return !a.Bool() && b.Bool()
 		}
+	} else {
+		return a.Kind() < b.Kind()
 	}
-
-	scope[self._lhs1] = __lhs1 // {oldlhs1, oklhs1}
-	scope[self._lhs2] = __lhs2 // {oldlhs2, oklhs2}
+	return fmt.Sprint(rangeValue(a)) < fmt.Sprint(rangeValue(b))
 }
 
 func (self *rangenode) setParent(n inode) {
@@ -506,7 +932,12 @@ func (self *declassnode) noNewline() bool {
 	return false
 }
 
-func (self *declassnode) resolve(scope map[string]interface{}, buf *bytes.Buffer, curIndent string, indent string, autoclose bool) {
+func (self *declassnode) resolve(scope map[string]interface{}, buf *bytes.Buffer, curIndent string, r *renderState) {
+	if s, ok := self._rhs.(string); ok && r.escape {
+		// strings written in the template are not escaped.
+		scope[self._lhs] = template.HTML(s)
+		return
+	}
 	scope[self._lhs] = self._rhs
 }
 
@@ -554,8 +985,14 @@ func (self *vdeclassnode) noNewline() bool {
 	return false
 }
 
-func (self *vdeclassnode) resolve(scope map[string]interface{}, buf *bytes.Buffer, curIndent string, indent string, autoclose bool) {
-	scope[self._lhs] = self._rhs.resolve(scope)
+func (self *vdeclassnode) resolve(scope map[string]interface{}, buf *bytes.Buffer, curIndent string, r *renderState) {
+	value := self._rhs.resolve(scope, r)
+	if r.escape {
+		// the value is escaped already.
+		scope[self._lhs] = template.HTML(value)
+		return
+	}
+	scope[self._lhs] = value
 }
 
 func (self *vdeclassnode) setParent(n inode) {
@@ -569,3 +1006,282 @@ func (self *vdeclassnode) nil() bool {
 func (self *vdeclassnode) setLHS(s string) {
 	self._lhs = s
 }
+
+// rendered returns the nodes that produce output, leaving out comments and
+// mixin definitions along with the line breaks that would surround them.
+func rendered(nodes []inode) []inode {
+	for i, n := range nodes {
+		if !isSilent(n) {
+			continue
+		}
+		out := append([]inode(nil), nodes[:i]...)
+		for _, n := range nodes[i+1:] {
+			if !isSilent(n) {
+				out = append(out, n)
+			}
+		}
+		return out
+	}
+	return nodes
+}
+
+func isSilent(n inode) bool {
+	switch n.(type) {
+	case *commentnode, *defnode:
+		return true
+	}
+	return false
+}
+
+// resolveChildren renders a list of sibling nodes that share the indentation
+// of the construct expanding them, as loops and mixins do.
+func resolveChildren(children []inode, scope map[string]interface{}, buf *bytes.Buffer, curIndent string, r *renderState, newline bool) bool {
+	for _, n := range rendered(children) {
+		if isAssignment(n) {
+			n.resolve(scope, buf, curIndent, r)
+			continue
+		}
+		if newline {
+			buf.WriteString("\n")
+			buf.WriteString(curIndent)
+		}
+		n.resolve(scope, buf, curIndent, r)
+		newline = !n.noNewline()
+	}
+	return newline
+}
+
+// isAssignment tells whether a node only assigns a value, and so takes no
+// line of the output.
+func isAssignment(n inode) bool {
+	switch n.(type) {
+	case *declassnode, *vdeclassnode:
+		return true
+	}
+	return false
+}
+
+// lastWritten returns the last of the nodes that is not an assignment, or
+// nil if there is none.
+func lastWritten(nodes []inode) inode {
+	for i := len(nodes) - 1; i >= 0; i-- {
+		if !isAssignment(nodes[i]) {
+			return nodes[i]
+		}
+	}
+	return nil
+}
+
+type mixinarg struct {
+	_atom interface{}
+	_path res
+}
+
+func (self mixinarg) value(scope map[string]interface{}, r *renderState) interface{} {
+	if !self._path.needsResolution {
+		return self._atom
+	}
+	v := self._path.resolveValue(scope, r)
+	if !v.IsValid() || !v.CanInterface() {
+		return nil
+	}
+	return v.Interface()
+}
+
+type defnode struct {
+	_parent      inode
+	_indentLevel int
+	_children    []inode
+
+	_name   string
+	_params []string
+}
+
+func (self *defnode) parent() inode {
+	return self._parent
+}
+
+func (self *defnode) indentLevel() int {
+	return self._indentLevel
+}
+
+func (self *defnode) setIndentLevel(i int) {
+	self._indentLevel = i
+}
+
+func (self *defnode) addChild(n inode) {
+	n.setParent(self)
+	self._children = append(self._children, n)
+}
+
+func (self *defnode) noNewline() bool {
+	return true
+}
+
+func (self *defnode) resolve(scope map[string]interface{}, buf *bytes.Buffer, curIndent string, r *renderState) {
+}
+
+func (self *defnode) setParent(n inode) {
+	self._parent = n
+}
+
+func (self *defnode) nil() bool {
+	return self == nil
+}
+
+type callnode struct {
+	_parent      inode
+	_indentLevel int
+	_children    []inode
+
+	_name string
+	_args []mixinarg
+}
+
+func (self *callnode) parent() inode {
+	return self._parent
+}
+
+func (self *callnode) indentLevel() int {
+	return self._indentLevel
+}
+
+func (self *callnode) setIndentLevel(i int) {
+	self._indentLevel = i
+}
+
+func (self *callnode) addChild(n inode) {
+	n.setParent(self)
+	self._children = append(self._children, n)
+}
+
+func (self *callnode) noNewline() bool {
+	return false
+}
+
+func (self *callnode) resolve(scope map[string]interface{}, buf *bytes.Buffer, curIndent string, r *renderState) {
+	def := r.lookupMixin(self._name)
+	if def == nil {
+		r.fail(fmt.Errorf("gohaml: no mixin named %s", self._name))
+		return
+	}
+	if r.depth >= maxMixinDepth {
+		r.fail(fmt.Errorf("gohaml: calls of mixin %s nest deeper than %d", self._name, maxMixinDepth))
+		return
+	}
+
+	local := make(map[string]interface{}, len(scope)+len(def._params))
+	for k, v := range scope {
+		local[k] = v
+	}
+	for i, param := range def._params {
+		if i < len(self._args) {
+			local[param] = self._args[i].value(scope, r)
+			if s, ok := local[param].(string); ok && r.escape && !self._args[i]._path.needsResolution {
+				// strings written in the call are not escaped.
+				local[param] = template.HTML(s)
+			}
+		} else {
+			local[param] = nil
+		}
+	}
+
+	r.depth++
+	r.frames = append(r.frames, &mixinFrame{self._children, scope})
+	resolveChildren(def._children, local, buf, curIndent, r, false)
+	r.frames = r.frames[:len(r.frames)-1]
+	r.depth--
+}
+
+func (self *callnode) setParent(n inode) {
+	self._parent = n
+}
+
+func (self *callnode) nil() bool {
+	return self == nil
+}
+
+type yieldnode struct {
+	_parent      inode
+	_indentLevel int
+}
+
+func (self *yieldnode) parent() inode {
+	return self._parent
+}
+
+func (self *yieldnode) indentLevel() int {
+	return self._indentLevel
+}
+
+func (self *yieldnode) setIndentLevel(i int) {
+	self._indentLevel = i
+}
+
+func (self *yieldnode) addChild(n inode) {
+	n.setParent(self)
+}
+
+func (self *yieldnode) noNewline() bool {
+	return false
+}
+
+func (self *yieldnode) resolve(scope map[string]interface{}, buf *bytes.Buffer, curIndent string, r *renderState) {
+	if len(r.frames) == 0 {
+		return
+	}
+	// the block belongs to the caller, so any yield inside of it refers to
+	// the frame enclosing the call.
+	frame := r.frames[len(r.frames)-1]
+	r.frames = r.frames[:len(r.frames)-1]
+	resolveChildren(frame.block, frame.scope, buf, curIndent, r, false)
+	r.frames = append(r.frames, frame)
+}
+
+func (self *yieldnode) setParent(n inode) {
+	self._parent = n
+}
+
+func (self *yieldnode) nil() bool {
+	return self == nil
+}
+
+type commentnode struct {
+	_parent      inode
+	_indentLevel int
+	_children    []inode
+
+	_text string
+}
+
+func (self *commentnode) parent() inode {
+	return self._parent
+}
+
+func (self *commentnode) indentLevel() int {
+	return self._indentLevel
+}
+
+func (self *commentnode) setIndentLevel(i int) {
+	self._indentLevel = i
+}
+
+func (self *commentnode) addChild(n inode) {
+	n.setParent(self)
+	self._children = append(self._children, n)
+}
+
+func (self *commentnode) noNewline() bool {
+	return true
+}
+
+func (self *commentnode) resolve(scope map[string]interface{}, buf *bytes.Buffer, curIndent string, r *renderState) {
+}
+
+func (self *commentnode) setParent(n inode) {
+	self._parent = n
+}
+
+func (self *commentnode) nil() bool {
+	return self == nil
+}
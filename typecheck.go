@@ -0,0 +1,291 @@
+package gohaml
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// CheckError is a problem found by CheckAgainst.
+type CheckError struct {
+	Line int
+	Path string
+	Msg  string
+}
+
+func (self CheckError) Error() string {
+	return fmt.Sprintf("line %d: %s: %s", self.Line, self.Path, self.Msg)
+}
+
+// CheckAgainst verifies the template against the type of the data it will
+// be rendered with. Top-level names are looked up as the fields and methods
+// of t, or as the values of t when it is a map. Every path, range target
+// and assignment is followed through the Go types, matching names the way
+// the FieldLookup of the engine does, and the paths that cannot resolve,
+// ranges over values that are not iterable and methods that take arguments
+// or do not return a single value are reported. Values of interface type
+// are not checked past that point.
+func (self *Engine) CheckAgainst(t reflect.Type) (errs []CheckError) {
+	c := &checker{
+		root:    t,
+		tree:    self.ast,
+		r:       &renderState{mixins: self.mixins, shared: self.shared, lookup: self.FieldLookup},
+		active:  make(map[*defnode]bool),
+		called:  make(map[*defnode]bool),
+		reports: make(map[CheckError]bool),
+	}
+	c.nodes(self.ast.nodes, make(map[string]reflect.Type), nil)
+
+	// mixins that are only called from other templates are checked with
+	// parameters of unknown type.
+	for _, def := range self.mixins {
+		if !c.called[def] {
+			c.def(def, make(map[string]reflect.Type), nil, nil)
+		}
+	}
+	sort.SliceStable(c.errs, func(i, j int) bool {
+		return c.errs[i].Line < c.errs[j].Line
+	})
+	return c.errs
+}
+
+// checker walks a tree with the types of the names in scope. A nil type
+// stands for a value whose type is not known until render time.
+type checker struct {
+	root    reflect.Type
+	tree    *tree
+	r       *renderState
+	active  map[*defnode]bool
+	called  map[*defnode]bool
+	reports map[CheckError]bool
+	errs    []CheckError
+}
+
+// checkFrame is the block passed to the mixin being checked, with the names
+// in scope where it was written.
+type checkFrame struct {
+	block []inode
+	env   map[string]reflect.Type
+	outer *checkFrame
+}
+
+func (self *checker) report(n inode, path string, format string, args ...interface{}) {
+	err := CheckError{self.tree.lines[n], path, fmt.Sprintf(format, args...)}
+	if !self.reports[err] {
+		self.reports[err] = true
+		self.errs = append(self.errs, err)
+	}
+}
+
+func (self *checker) nodes(nodes []inode, env map[string]reflect.Type, frame *checkFrame) {
+	for _, n := range nodes {
+		self.node(n, env, frame)
+	}
+}
+
+func (self *checker) node(n inode, env map[string]reflect.Type, frame *checkFrame) {
+	switch n := n.(type) {
+	case *node:
+		self.path(n, n._remainder, env)
+		for _, attr := range n._attrs {
+			self.path(n, attr.key, env)
+			if t, ok := self.path(n, attr.value, env); ok && attr.isSplat() {
+				self.splat(n, attr.value.value, t)
+			}
+		}
+		self.nodes(n._children, env, frame)
+	case *rangenode:
+		key, value, keyless, ok := self.iterate(n, env)
+		if n._lhs2 == "" && keyless {
+			key = value
+		}
+		names := []string{n._lhs1, n._lhs2, "loop"}
+		types := []reflect.Type{key, value, reflect.TypeOf(&Loop{})}
+		saved := make([]reflect.Type, len(names))
+		present := make([]bool, len(names))
+		for i, name := range names {
+			saved[i], present[i] = env[name]
+		}
+		if ok {
+			for i, name := range names {
+				if name != "" && name != "_" {
+					env[name] = types[i]
+				}
+			}
+			self.nodes(n._children, env, frame)
+		}
+		for i, name := range names {
+			restore(env, name, saved[i], present[i])
+		}
+	case *declassnode:
+		env[n._lhs] = reflect.TypeOf(n._rhs)
+	case *vdeclassnode:
+		self.path(n, n._rhs, env)
+		// the value is assigned in its rendered form.
+		env[n._lhs] = reflect.TypeOf("")
+	case *callnode:
+		var types []reflect.Type
+		for _, arg := range n._args {
+			if arg._path.needsResolution {
+				t, _ := self.path(n, arg._path, env)
+				types = append(types, t)
+			} else {
+				types = append(types, reflect.TypeOf(arg._atom))
+			}
+		}
+		if def := self.r.lookupMixin(n._name); def != nil && !self.active[def] {
+			self.called[def] = true
+			local := make(map[string]reflect.Type)
+			for name, t := range env {
+				local[name] = t
+			}
+			self.def(def, local, types, &checkFrame{n._children, env, frame})
+		}
+	case *yieldnode:
+		if frame != nil {
+			self.nodes(frame.block, frame.env, frame.outer)
+		}
+	}
+}
+
+func restore(env map[string]reflect.Type, name string, t reflect.Type, ok bool) {
+	if ok {
+		env[name] = t
+	} else {
+		delete(env, name)
+	}
+}
+
+func (self *checker) def(def *defnode, env map[string]reflect.Type, args []reflect.Type, frame *checkFrame) {
+	for i, param := range def._params {
+		env[param] = nil
+		if i < len(args) {
+			env[param] = args[i]
+		}
+	}
+	self.active[def] = true
+	self.nodes(def._children, env, frame)
+	delete(self.active, def)
+}
+
+// splat reports the attribute splats whose value is not a map or struct.
+func (self *checker) splat(n inode, path string, t reflect.Type) {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil {
+		return
+	}
+	switch t.Kind() {
+	case reflect.Map, reflect.Struct, reflect.Interface:
+		return
+	}
+	self.report(n, path, "cannot use %s as attributes", t)
+}
+
+// iterate returns the types a range binds to its two variables, following
+// rangeItems.
+func (self *checker) iterate(n *rangenode, env map[string]reflect.Type) (key reflect.Type, value reflect.Type, keyless bool, ok bool) {
+	t := reflect.TypeOf(n._count)
+	if n._rhs.needsResolution {
+		if t, ok = self.path(n, n._rhs, env); !ok || t == nil {
+			return
+		}
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	index := reflect.TypeOf(0)
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		return index, t.Elem(), false, true
+	case reflect.Map:
+		return t.Key(), t.Elem(), false, true
+	case reflect.String:
+		return index, t, false, true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return index, index, false, true
+	case reflect.Chan:
+		if t.ChanDir()&reflect.RecvDir != 0 {
+			return index, t.Elem(), true, true
+		}
+	case reflect.Func:
+		if isSeq(t) {
+			if yield := t.In(0); yield.NumIn() == 2 {
+				return yield.In(0), yield.In(1), false, true
+			}
+			return index, t.In(0).In(0), true, true
+		}
+	case reflect.Interface:
+		return nil, nil, false, true
+	}
+	target := n._rhs.value
+	if !n._rhs.needsResolution {
+		target = fmt.Sprint(n._count)
+	}
+	self.report(n, target, "cannot range over %s", t)
+	return nil, nil, false, false
+}
+
+// path returns the type a path resolves to, or false after reporting why
+// it does not resolve.
+func (self *checker) path(n inode, r res, env map[string]reflect.Type) (t reflect.Type, ok bool) {
+	if !r.needsResolution {
+		return reflect.TypeOf(""), true
+	}
+	keyPath := strings.Split(r.value, ".")
+	var msg string
+	if local, found := env[keyPath[0]]; found {
+		t = local
+	} else if t, msg = self.lookup(self.root, keyPath[0]); msg != "" {
+		self.report(n, r.value, "%s", msg)
+		return nil, false
+	}
+	for _, key := range keyPath[1:] {
+		if t, msg = self.lookup(t, key); msg != "" {
+			self.report(n, r.value, "%s", msg)
+			return nil, false
+		}
+	}
+	return t, true
+}
+
+// lookup follows one element of a path the way res.resolveValue does.
+func (self *checker) lookup(t reflect.Type, key string) (reflect.Type, string) {
+	if t == nil {
+		return nil, ""
+	}
+	if m, ok := t.MethodByName(self.r.lookup.method(t, key)); ok {
+		in := m.Type.NumIn()
+		if t.Kind() != reflect.Interface {
+			in-- // the receiver
+		}
+		switch {
+		case in != 0:
+			return nil, fmt.Sprintf("method %s of %s takes %d argument(s)", key, t, in)
+		case m.Type.NumOut() != 1:
+			return nil, fmt.Sprintf("method %s of %s returns %d values", key, t, m.Type.NumOut())
+		}
+		return m.Type.Out(0), ""
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Interface:
+		return nil, ""
+	case reflect.Struct:
+		if _, typ, ok := self.r.lookup.field(t, key); ok {
+			return typ, ""
+		}
+		return nil, fmt.Sprintf("no field or method %s in %s", key, t)
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Sprintf("cannot look up %s in %s, which is not keyed by strings", key, t)
+		}
+		return t.Elem(), ""
+	}
+	return nil, fmt.Sprintf("cannot look up %s in %s", key, t)
+}